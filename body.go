@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bodyOptions returns the options in use which inspect the response body.
+func bodyOptions(opts Options) []string {
+	var used []string
+	if len(opts.JsonKey) > 0 {
+		used = append(used, "--json-key/--json-value")
+	}
+	if opts.String != "" {
+		used = append(used, "--string")
+	}
+	if opts.Regex != "" {
+		used = append(used, "--regex")
+	}
+	if len(opts.Forbid) > 0 {
+		used = append(used, "--forbid")
+	}
+	if opts.ForbidCtrl {
+		used = append(used, "--forbid-control-chars")
+	}
+	if opts.StatusFromJson != "" {
+		used = append(used, "--status-from-json")
+	}
+	if opts.GrpcWeb != "" {
+		used = append(used, "--grpc-web")
+	}
+	if opts.PromMetric != "" {
+		used = append(used, "--prom-metric")
+	}
+	if opts.Sitemap {
+		used = append(used, "--validate-sitemap")
+	}
+	if opts.Robots != "" {
+		used = append(used, "--validate-robots")
+	}
+	if opts.HealthFormat != "" {
+		used = append(used, "--health-format")
+	}
+	return used
+}
+
+// needBody reports whether the response body has to be kept in memory.
+func needBody(opts Options) bool {
+	return opts.Verbose || opts.StateFile != "" || opts.NoCompress || opts.AcceptEncoding != "" || len(bodyOptions(opts)) > 0
+}
+
+// parseSizeTolerance parses an absolute byte count or a percentage of
+// expected.
+func parseSizeTolerance(s string, expected int64) (int64, error) {
+	if strings.HasSuffix(s, "%") {
+		p, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || p < 0 {
+			return 0, fmt.Errorf("invalid size tolerance: %s", s)
+		}
+		return int64(float64(expected) * p / 100), nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size tolerance: %s", s)
+	}
+	return n, nil
+}
+
+// checkExpectedSize reports CRITICAL when size is outside expected±tolerance.
+func checkExpectedSize(size, expected, tolerance int64, result *Result) {
+	if size < expected-tolerance || size > expected+tolerance {
+		result.Add(NagiosCritical, "body size %d bytes differs from expected %d (tolerance %d)", size, expected, tolerance)
+	}
+}
+
+// splitList flattens repeated and comma separated option values.
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
+
+// splitPatterns is splitList for patterns, where \, stands for a comma
+// within a pattern.
+func splitPatterns(values []string) []string {
+	var escaped []string
+	for _, value := range values {
+		escaped = append(escaped, strings.ReplaceAll(value, `\,`, "\x00"))
+	}
+	list := splitList(escaped)
+	for i, v := range list {
+		list[i] = strings.ReplaceAll(v, "\x00", ",")
+	}
+	return list
+}
+
+// checkSizeRange requires the body size to be within min and max, either
+// of which is ignored when zero.
+func checkSizeRange(size, min, max int64, result *Result) {
+	if min > 0 && size < min {
+		result.Add(NagiosCritical, "page size %d bytes below minimum %d", size, min)
+	}
+	if max > 0 && size > max {
+		result.Add(NagiosCritical, "page size %d bytes above maximum %d", size, max)
+	}
+}
+
+// snippet returns a short quoted excerpt of body around [start, end).
+func snippet(body []byte, start, end int) string {
+	const context = 30
+	from := start - context
+	if from < 0 {
+		from = 0
+	}
+	to := end + context
+	if to > len(body) {
+		to = len(body)
+	}
+	s := strconv.Quote(string(body[from:to]))
+	if from > 0 {
+		s = "..." + s
+	}
+	if to < len(body) {
+		s += "..."
+	}
+	return s
+}
+
+// checkString requires s to appear in body.
+func checkString(body []byte, s string, result *Result) {
+	if !bytes.Contains(body, []byte(s)) {
+		result.Add(NagiosCritical, "string '%s' not found in response body", s)
+	}
+}
+
+// checkRegex requires re to match body.
+func checkRegex(body []byte, re *regexp.Regexp, result *Result) {
+	if !re.Match(body) {
+		result.Add(NagiosCritical, "regex '%s' not found in response body", re)
+	}
+}
+
+// checkForbidden reports CRITICAL for every pattern found in body.
+func checkForbidden(body []byte, patterns []string, result *Result) {
+	for _, pattern := range patterns {
+		i := bytes.Index(body, []byte(pattern))
+		if i < 0 {
+			continue
+		}
+		result.Add(NagiosCritical, "forbidden pattern `%s` found: %s", pattern, snippet(body, i, i+len(pattern)))
+	}
+}
+
+// checkControlChars reports the offset of the first control character in
+// body. Tab, newline and carriage return are allowed.
+func checkControlChars(body []byte, state int, result *Result) {
+	for i, b := range body {
+		if (b < 0x20 && b != '\t' && b != '\n' && b != '\r') || b == 0x7f {
+			result.Add(state, "control character 0x%02x at offset %d: %s", b, i, snippet(body, i, i+1))
+			return
+		}
+	}
+}
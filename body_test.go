@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPatterns(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		// --forbid "stack trace","SQLException" reaches us as one value
+		{[]string{"stack trace,SQLException"}, []string{"stack trace", "SQLException"}},
+		{[]string{"stack trace", "SQLException"}, []string{"stack trace", "SQLException"}},
+		{[]string{`"error":1\,"fatal"`, "panic"}, []string{`"error":1,"fatal"`, "panic"}},
+		{[]string{" a , ,b"}, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		if got := splitPatterns(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPatterns(%q) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestCheckForbidden(t *testing.T) {
+	body := []byte("java.sql.SQLException: boom\n\tat stack trace follows")
+	tests := []struct {
+		values []string
+		found  int
+	}{
+		{[]string{"stack trace,SQLException"}, 2},
+		{[]string{"stack trace", "SQLException"}, 2},
+		{[]string{"Exception: boom\\,"}, 0},
+		{[]string{"OutOfMemoryError"}, 0},
+	}
+	for _, tt := range tests {
+		var result Result
+		checkForbidden(body, splitPatterns(tt.values), &result)
+		if len(result.Messages) != tt.found {
+			t.Errorf("checkForbidden(%q) = %v, want %d patterns found", tt.values, result.Messages, tt.found)
+		}
+		if tt.found > 0 && result.Status != NagiosCritical {
+			t.Errorf("checkForbidden(%q) status = %s, want CRITICAL", tt.values, statusString(result.Status))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// captureWriter writes the response body to a temporary file next to the
+// capture file and remembers the first write error, so that it can be told
+// apart from an error reading the body. The capture file itself is only
+// replaced by Commit, once the whole body has been written.
+type captureWriter struct {
+	f    *os.File
+	path string
+	err  error
+}
+
+// createCapture creates the temporary file for the response body to path.
+func createCapture(path string) (*captureWriter, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return nil, err
+	}
+	// TempFile creates it 0600, the capture file is as readable as before
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &captureWriter{f: f, path: path}, nil
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return n, err
+}
+
+// Commit closes the temporary file and renames it to the capture file. It
+// returns the first error writing or closing it, and then leaves the capture
+// file as it was.
+func (w *captureWriter) Commit() error {
+	err := w.f.Close()
+	if w.err != nil {
+		err = w.err
+	}
+	if err == nil {
+		err = os.Rename(w.f.Name(), w.path)
+	}
+	if err != nil {
+		os.Remove(w.f.Name())
+	}
+	return err
+}
+
+// Abort removes the temporary file, for a body that could not be read.
+func (w *captureWriter) Abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCaptureWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit bool
+		want   string
+	}{
+		{"commit", true, "new body"},
+		{"abort", false, "old body"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "body")
+		if err := ioutil.WriteFile(path, []byte("old body"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		w, err := createCapture(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("new body"))
+		if got, _ := ioutil.ReadFile(path); string(got) != "old body" {
+			t.Errorf("%s: capture file = %q before the body was complete", tt.name, got)
+		}
+		if tt.commit {
+			if err := w.Commit(); err != nil {
+				t.Fatalf("%s: Commit() = %v", tt.name, err)
+			}
+		} else {
+			w.Abort()
+		}
+		if got, _ := ioutil.ReadFile(path); string(got) != tt.want {
+			t.Errorf("%s: capture file = %q, want %q", tt.name, got, tt.want)
+		}
+		// no temporary file is left behind either way
+		if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+			t.Errorf("%s: %d files in the directory, want 1", tt.name, len(files))
+		}
+	}
+}
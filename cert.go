@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// parseCertDays parses a "warn,crit" pair of day counts. When only one
+// number is given it is the warning threshold.
+func parseCertDays(s string) (warn int, crit int, err error) {
+	t := strings.SplitN(s, ",", 2)
+	warn, err = strconv.Atoi(strings.TrimSpace(t[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid certificate days: %s", s)
+	}
+	if len(t) == 2 {
+		crit, err = strconv.Atoi(strings.TrimSpace(t[1]))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid certificate days: %s", s)
+		}
+	}
+	return warn, crit, nil
+}
+
+// daysLeft returns the number of whole days until cert expires. It is
+// negative for an already expired certificate.
+func daysLeft(cert *x509.Certificate, now time.Time) int {
+	return int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24))
+}
+
+func certName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	return cert.Subject.String()
+}
+
+// checkChainExpiry evaluates every certificate presented by the server and
+// reports the one which expires first.
+func checkChainExpiry(certs []*x509.Certificate, warn, crit int, result *Result) {
+	if len(certs) == 0 {
+		result.Add(NagiosUnknown, "no certificate presented by server")
+		return
+	}
+	now := time.Now()
+	soonest := 0
+	for i, cert := range certs {
+		if cert.NotAfter.Before(certs[soonest].NotAfter) {
+			soonest = i
+		}
+	}
+	cert := certs[soonest]
+	days := daysLeft(cert, now)
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("cert_chain_days=%d;%d:;%d:", days, warn, crit))
+
+	expiry := cert.NotAfter.UTC().Format("2006-01-02 15:04:05 MST")
+	if cert.NotAfter.Before(now) {
+		result.Add(NagiosCritical, "Certificate '%s' (chain #%d) expired on %s", certName(cert), soonest, expiry)
+	} else if days < crit {
+		result.Add(NagiosCritical, "Certificate '%s' (chain #%d) expires in %d days (%s)", certName(cert), soonest, days, expiry)
+	} else if days < warn {
+		result.Add(NagiosWarning, "Certificate '%s' (chain #%d) expires in %d days (%s)", certName(cert), soonest, days, expiry)
+	}
+}
+
+// checkCertExpiry evaluates the expiry of the server certificate.
+func checkCertExpiry(cert *x509.Certificate, warn, crit int, result *Result) {
+	now := time.Now()
+	days := daysLeft(cert, now)
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("cert_days=%d;%d:;%d:", days, warn, crit))
+
+	expiry := cert.NotAfter.UTC().Format("2006-01-02 15:04:05 MST")
+	if cert.NotAfter.Before(now) {
+		result.Add(NagiosCritical, "Certificate '%s' expired on %s", certName(cert), expiry)
+	} else if days < crit {
+		result.Add(NagiosCritical, "Certificate '%s' expires in %d days (%s)", certName(cert), days, expiry)
+	} else if days < warn {
+		result.Add(NagiosWarning, "Certificate '%s' expires in %d days (%s)", certName(cert), days, expiry)
+	} else {
+		result.Messages = append(result.Messages, fmt.Sprintf("Certificate '%s' will expire on %s", certName(cert), expiry))
+	}
+}
+
+// expiredCertificate returns the certificate whose expiry failed the
+// verification reported by err, or nil for any other error.
+func expiredCertificate(err error) *x509.Certificate {
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) && invalid.Reason == x509.Expired {
+		return invalid.Cert
+	}
+	return nil
+}
+
+// failCertExpired reports a certificate whose expiry failed the handshake
+// the way -C reports it on a response, and exits.
+func failCertExpired(opts Options, url string, cert *x509.Certificate, warn, crit int, elapsed time.Duration) {
+	result := Result{}
+	checkCertExpiry(cert, warn, crit, &result)
+	line := fmt.Sprintf("HTTP %s - %s |%s", statusString(result.Status), result.Messages[0], strings.Join(result.Perfdata, " "))
+	logResult(opts, result.Status, line)
+	if opts.Output == "json" {
+		printJSON(newJSONOutput(result, url, nil, 0, elapsed, result.Perfdata))
+		exit(opts, result.Status)
+	}
+	if opts.PerfdataOnly {
+		fmt.Println(strings.Join(result.Perfdata, " "))
+		exit(opts, result.Status)
+	}
+	fmt.Println(line)
+	exit(opts, result.Status)
+}
+
+// spkiSHA256 returns the base64 encoded SHA-256 hash of the public key of
+// cert, as used for HPKP pins.
+func spkiSHA256(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// checkSPKIPins requires the public key of cert to match one of pins.
+func checkSPKIPins(cert *x509.Certificate, pins []string, result *Result) {
+	actual := spkiSHA256(cert)
+	for _, pin := range pins {
+		if strings.TrimPrefix(pin, "sha256/") == actual {
+			return
+		}
+	}
+	result.Add(NagiosCritical, "public key of '%s' is not pinned: sha256/%s, expected one of %s", certName(cert), actual, strings.Join(pins, ", "))
+}
+
+// loadPKCS12 decodes a client certificate, its private key and any CA
+// certificates from a PKCS#12 file.
+func loadPKCS12(path, password string) (tls.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	key, cert, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%s: %s", path, err)
+	}
+	c := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+	for _, ca := range chain {
+		c.Certificate = append(c.Certificate, ca.Raw)
+	}
+	return c, nil
+}
+
+// hostname strips an optional port from a Host header value.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
+
+// verifyChain verifies the certificates presented by the server for host
+// the way the TLS stack would. A nil roots uses the system pool.
+func verifyChain(certs []*x509.Certificate, host string, roots *x509.CertPool) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate presented by server")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// selfSignedCert returns a certificate for 127.0.0.1 valid until notAfter.
+func selfSignedCert(t *testing.T, notAfter time.Time) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:             notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func TestExpiredCertificate(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		notAfter time.Time
+		expired  bool
+	}{
+		{"expired", time.Now().Add(-48 * time.Hour), true},
+		{"valid", time.Now().Add(48 * time.Hour), false},
+	} {
+		pair, cert := selfSignedCert(t, tt.notAfter)
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		srv.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+		srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		srv.StartTLS()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(cert)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		srv.Close()
+
+		got := expiredCertificate(err)
+		if tt.expired && (got == nil || !got.NotAfter.Equal(cert.NotAfter)) {
+			t.Errorf("%s: expiredCertificate(%v) = %v, want the server certificate", tt.name, err, got)
+		}
+		if !tt.expired && got != nil {
+			t.Errorf("%s: expiredCertificate(%v) = %v, want nil", tt.name, err, got)
+		}
+	}
+
+	if expiredCertificate(errors.New("x509: certificate signed by unknown authority")) != nil {
+		t.Error("expiredCertificate of an unrelated error is not nil")
+	}
+}
+
+func TestCheckCertExpiry(t *testing.T) {
+	_, cert := selfSignedCert(t, time.Now().Add(-36*time.Hour))
+	var result Result
+	checkCertExpiry(cert, 30, 14, &result)
+	if result.Status != NagiosCritical {
+		t.Errorf("status = %s, want CRITICAL", statusString(result.Status))
+	}
+	if len(result.Perfdata) != 1 || result.Perfdata[0] != "cert_days=-2;30:;14:" {
+		t.Errorf("perfdata = %v, want cert_days=-2;30:;14:", result.Perfdata)
+	}
+}
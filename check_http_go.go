@@ -3,16 +3,23 @@ package main
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/icza/dyno"
 	flags "github.com/jessevdk/go-flags"
 	"golang.org/x/net/http2"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"time"
@@ -24,22 +31,142 @@ import (
 
 type Options struct {
 	Verbose        bool     `short:"v" long:"verbose"    description:"Show verbose debug information"`
+	VerboseOk      bool     `long:"verbose-ok" description:"On OK, describe which assertions passed"`
+	VerboseLimit   int      `long:"verbose-limit" description:"Maximum bytes of body shown in verbose mode, 0 for unlimited" default:"65536"`
 	Vhost          string   `short:"H" long:"vhost"      description:"Host header"`
 	Ipaddr         string   `short:"I" long:"ipaddr"     description:"IP address"`
 	Port           int      `short:"p" long:"port"       description:"TCP Port" default:"0"`
-	Warn           float64  `short:"w" long:"warn"       description:"Warning time in second" default:"5.0"`
-	Crit           float64  `short:"c" long:"crit"       description:"Critical time in second" default:"10.0"`
+	Warn           float64  `short:"w" long:"warn"       description:"Warning time in seconds, or in milliseconds with --unit ms" default:"5.0"`
+	Crit           float64  `short:"c" long:"crit"       description:"Critical time in seconds, or in milliseconds with --unit ms" default:"10.0"`
+	Unit           string   `long:"unit" description:"Unit of the response time in the output and of -w/-c" choice:"s" choice:"ms" default:"s"`
 	Headers        []string `short:"k" long:"header"    description:"additional headers, acceptable multiple times"`
+	IfNoneMatch    string   `long:"if-none-match" description:"Send a conditional request with this ETag" unquote:"false"`
+	IfModSince     string   `long:"if-modified-since" description:"Send a conditional request with this time (HTTP-date or RFC 3339)"`
+	HeaderFile     string   `long:"header-file" description:"Read additional headers from file (Name: Value per line)"`
 	Timeout        int      `short:"t" long:"timeout"    description:"Timeout in second" default:"10"`
+	ConnTimeout    int      `long:"connect-timeout" description:"Timeout of establishing the connection in second, defaults to --timeout"`
+	TimeoutState   string   `long:"timeout-state" description:"State when the request or the connection times out" choice:"critical" choice:"unknown" default:"critical"`
+	DnsTimeout     float64  `long:"dns-timeout" description:"Timeout of each DNS query in second"`
+	DnsRetries     int      `long:"dns-retries" description:"Number of retries of a failed DNS lookup" default:"0"`
+	H2c            bool     `long:"h2c" description:"Speak HTTP/2 over cleartext with prior knowledge"`
+	Proxy          string   `long:"proxy" description:"Connect through this HTTP or HTTPS proxy (http[s]://host:port)"`
+	PacURL         string   `long:"pac-url" description:"Choose the proxy with this proxy auto-config (PAC) file (http, https or file URL)"`
+	ProxyCAFile    string   `long:"proxy-ca-file" description:"PEM file with the CA certificates to verify an https proxy"`
+	SshJump        string   `long:"ssh-jump" description:"Connect through an SSH jump host ([user@]host[:port])"`
+	SshKey         string   `long:"ssh-key" description:"Private key file for --ssh-jump (ssh-agent is also used)"`
+	SshKnownHosts  string   `long:"ssh-known-hosts" description:"known_hosts file for --ssh-jump (default: ~/.ssh/known_hosts)"`
 	Uri            string   `short:"u" long:"uri"        description:"URI" default:"/"`
+	RawPath        bool     `long:"raw-path" description:"Send the path of the URI exactly as given, without normalizing its escaping"`
+	Url            string   `long:"url" description:"Full URL of the target, overrides --ipaddr, --port, --uri and --ssl"`
 	Ssl            bool     `short:"S" long:"ssl"        description:"Enable TLS"`
-	Expect         string   `short:"e" long:"expect"     description:"Expected status codes (csv)" default:""`
-	JsonKey        string   `long:"json-key"   description:"JSON key "`
-	JsonValue      string   `long:"json-value" description:"Expected json value"`
+	Expect         string   `short:"e" long:"expect"     description:"Expected status codes (csv), ranges such as 200-299 and classes such as 2xx are accepted" default:""`
+	ExpectNot      string   `long:"expect-not" description:"Status codes which are CRITICAL (csv, same syntax as --expect), taking precedence over --expect"`
+	Strict2xx      bool     `long:"strict-2xx" description:"Without --expect, warn about any status code outside 2xx"`
+	Follow         bool     `short:"f" long:"follow"     description:"Follow redirects, assertions apply to the final response"`
+	OnRedirect     string   `long:"onredirect" description:"How to handle a redirect response, sticky follows only redirects to the same host" choice:"ok" choice:"warning" choice:"critical" choice:"follow" choice:"sticky"`
+	MaxRedirs      int      `long:"max-redirs" description:"Maximum number of redirects to follow" default:"15"`
+	RedirectBody   int64    `long:"redirect-max-body" description:"Warning when a redirect response has a body larger than this many bytes" default:"-1"`
+	RedirectWarn   int      `long:"redirect-warn" description:"Warning when following more than this many redirects" default:"-1"`
+	ExpectRedirect string   `long:"expect-redirect" description:"Expected Location of a redirect response, a trailing * matches a prefix"`
+	NoRedirect     bool     `long:"no-redirect-expected" description:"Critical if the response, or any response while following, is a redirect"`
+	AssertInter    string   `long:"assert-intermediate" description:"Expected status codes (csv) of every redirect when following"`
+	JsonKey        []string `long:"json-key"   description:"JSON key, acceptable multiple times paired with --json-value"`
+	JsonValue      []string `long:"json-value" description:"Expected json value"`
+	JsonOp         string   `long:"json-op" description:"Compare the JSON value numerically with --json-value" choice:"eq" choice:"ne" choice:"lt" choice:"le" choice:"gt" choice:"ge"`
+	JsonNested     bool     `long:"json-decode-nested" description:"Parse the string value of --json-key as JSON and check --json-subkey in it"`
+	JsonSubkey     string   `long:"json-subkey" description:"JSON key within the nested JSON of --json-key"`
+	Data           string   `short:"d" long:"data"       description:"Request body, {{now}}, {{unixtime}} and {{uuid}} are substituted"`
+	DataFile       string   `long:"data-file" description:"Read the request body from this file as is"`
+	HmacSecret     string   `long:"hmac-secret" description:"File containing the secret to sign the request with HMAC"`
+	HmacHeader     string   `long:"hmac-header" description:"Header to put the HMAC signature in" default:"X-Signature"`
+	HmacAlgo       string   `long:"hmac-algo" description:"HMAC hash algorithm" choice:"sha1" choice:"sha256" choice:"sha512" default:"sha256"`
+	HmacString     string   `long:"hmac-string" description:"String to sign, {{method}}, {{path}}, {{timestamp}} and {{body}} are substituted" default:"{{method}}\n{{path}}\n{{timestamp}}\n{{body}}"`
+	HmacTsHeader   string   `long:"hmac-timestamp-header" description:"Header to put the signing timestamp in" default:"X-Timestamp"`
 	Method         string   `short:"j" long:"method"     description:"HTTP METHOD (GET, HEAD, POST)" default:"GET"`
+	Auth           string   `short:"a" long:"authorization" description:"Basic authentication as user:password"`
+	Bearer         string   `long:"bearer" description:"Send Authorization: Bearer with this token"`
 	UserAgent      string   `short:"A" long:"useragent"  description:"User-Agent header" default:"check_http_go"`
 	ClientCertFile string   `short:"J" long:"client-cert" description:"Client Certificate File"`
 	PrivateKeyFile string   `short:"K" long:"private-key" description:"Private Key File"`
+	ExpectSize     int64    `long:"expect-size" description:"Expected body size in bytes"`
+	MinSize        int64    `long:"min-size" description:"Minimum body size in bytes"`
+	MaxSize        int64    `long:"max-size" description:"Maximum body size in bytes"`
+	SizeTolerance  string   `long:"size-tolerance" description:"Tolerance of --expect-size in bytes or percent (e.g. 5%)" default:"0"`
+	Trace          bool     `long:"trace" description:"Add perfdata of the DNS, connect, TLS and time to first byte phases"`
+	MaxBodyTime    float64  `long:"max-body-time" description:"Warn when the body takes longer than this many seconds after the first byte"`
+	Throughput     bool     `long:"throughput" description:"Add perfdata splitting the response time and the body bytes at the first byte, with the body transfer rate"`
+	VaryEncoding   bool     `long:"check-vary-encoding" description:"Warn when a compressed response does not have Vary: Accept-Encoding"`
+	AcceptEncoding string   `long:"accept-encoding" description:"Send this Accept-Encoding, decode a gzip or deflate body for the checks and add its decoded size as perfdata (left out for other codings)"`
+	NoCompress     bool     `long:"no-compression" description:"Request an identity encoded body and report its uncompressed size"`
+	WarnUncompress int64    `long:"warn-uncompressed-threshold" description:"Warn when a compressible body larger than this many bytes is not compressed"`
+	ConnectOnly    bool     `long:"connect-only" description:"Only establish the connection (and TLS handshake with -S), no request is sent"`
+	CaptureFile    string   `long:"capture-to-file" description:"Write the decoded response body to this file, overwriting it"`
+	NoBody         bool     `long:"no-body" description:"Do not download the response body, size is taken from Content-Length"`
+	Cookies        []string `long:"cookie" description:"Cookie to send as name=value, acceptable multiple times"`
+	ForbidCookie   []string `long:"forbid-setcookie" description:"Cookie name which must not be set, acceptable multiple times"`
+	CookieValue    []string `long:"expect-cookie-value" description:"Cookie value which must match a regex, as name=regex, acceptable multiple times" unquote:"false"`
+	RedactCookie   bool     `long:"redact-cookie-value" description:"Do not show the actual value when --expect-cookie-value fails"`
+	ForbidCookieSt string   `long:"forbid-setcookie-state" description:"State when a forbidden cookie is set" choice:"warning" choice:"critical" default:"critical"`
+	String         string   `short:"s" long:"string" description:"String to expect in the response body"`
+	Regex          string   `short:"r" long:"regex" description:"Regular expression to expect in the response body"`
+	Forbid         []string `long:"forbid" description:"Forbidden body patterns (csv, \\, for a comma in a pattern), acceptable multiple times" unquote:"false"`
+	ForbidCtrl     bool     `long:"forbid-control-chars" description:"Fail when the decoded body contains control characters other than tab and newline"`
+	ForbidCtrlSt   string   `long:"forbid-control-chars-state" description:"State when a control character is found" choice:"warning" choice:"critical" default:"warning"`
+	ProbeMethod    string   `long:"probe-method" description:"Send OPTIONS and check that the Allow header lists this method"`
+	HdrThreshold   []string `long:"header-threshold" description:"Thresholds of a numeric header, e.g. X-RateLimit-Remaining:<100:crit,<500:warn, acceptable multiple times"`
+	MaxHeaderBytes int64    `long:"max-header-bytes" description:"Critical when the response header is larger than this many bytes"`
+	ExpectHeader   []string `long:"expect-header" description:"Response header as Name:Value, or Name to only require its presence, acceptable multiple times"`
+	RequireHeaders []string `long:"require-headers" description:"Headers which must be present in the response (csv), acceptable multiple times"`
+	RequireHdrSt   string   `long:"require-headers-state" description:"State when a required header is missing" choice:"warning" choice:"critical" default:"critical"`
+	ReportAltSvc   bool     `long:"report-altsvc" description:"Show the alternative services advertised by Alt-Svc"`
+	ExpectAltSvc   string   `long:"expect-altsvc" description:"Alt-Svc entry which must be advertised, e.g. h3=\":443\"" unquote:"false"`
+	ExpectAllow    []string `long:"expect-allow" description:"Methods the Allow header must list exactly (csv), acceptable multiple times"`
+	AuthRealm      string   `long:"expect-auth-realm" description:"Expected realm of the WWW-Authenticate challenge of a 401 response, which is then expected unless -e is given"`
+	HeaderOrder    string   `long:"expect-header-order" description:"Response headers (csv) which must appear in this order, HTTP/1.1 only"`
+	MaxClockSkew   float64  `long:"max-clock-skew" description:"Maximum skew of the server Date header in second"`
+	MaxCacheAge    int64    `long:"max-cache-age" description:"Warn when the Age header exceeds this many seconds"`
+	AgeMissing     string   `long:"age-missing" description:"Missing Age header is a cache miss or UNKNOWN" choice:"miss" choice:"unknown" default:"miss"`
+	StateFile      string   `long:"state-file" description:"File to keep a fingerprint of the response for change detection"`
+	ExpectChanged  bool     `long:"expect-changed" description:"Response must change between runs (with --state-file)"`
+	ExpectSame     bool     `long:"expect-unchanged" description:"Response must not change between runs (with --state-file)"`
+	Http2          string   `long:"http2" description:"Negotiate HTTP/2 when the server offers it (auto), require it (force) or use HTTP/1.1 only (off)" choice:"auto" choice:"force" choice:"off" default:"auto"`
+	ForbidHttp10   bool     `long:"forbid-http10" description:"Warn when the response is served over HTTP/1.0"`
+	AdviseCert     bool     `long:"advise-cert-validity" description:"Note when the certificate would fail verification, without changing the state"`
+	TlsPerfdata    bool     `long:"tls-perfdata" description:"Add the negotiated TLS version as tls_version perfdata"`
+	SslVersion     string   `long:"ssl-version" description:"Minimum TLS version (1.0, 1.1, 1.2, 1.3), a trailing + requires exactly that version"`
+	CACert         string   `long:"ca-cert" description:"PEM file with the CA certificates to verify the server, instead of the system roots"`
+	Insecure       bool     `long:"insecure" description:"Do not verify the server certificate"`
+	LegacyCiphers  bool     `long:"allow-legacy-ciphers" description:"Permit insecure cipher suites and TLS versions for legacy servers"`
+	RequirePfs     bool     `long:"require-pfs" description:"Require a cipher suite with forward secrecy"`
+	RequirePfsSt   string   `long:"require-pfs-state" description:"State when the cipher suite has no forward secrecy" choice:"warning" choice:"critical" default:"critical"`
+	ExpectCurve    string   `long:"expect-curve" description:"Warn unless this key exchange group is negotiated, e.g. X25519 or P-256"`
+	MinChainLength int      `long:"min-chain-length" description:"Minimum number of certificates presented by the server"`
+	ChainLength    int      `long:"expect-chain-length" description:"Exact number of certificates presented by the server"`
+	CertDays       string   `short:"C" long:"certificate" description:"Check expiry of the server certificate (warn,crit days), response time is only checked with -w/-c"`
+	PinSPKI        []string `long:"pin-spki-sha256" description:"Base64 SHA-256 of the certificate public key, acceptable multiple times for key rotation"`
+	ChainDays      string   `long:"check-chain-expiry" description:"Check expiry of every certificate in the chain (warn,crit days)"`
+	PromMetric     string   `long:"prom-metric" description:"Prometheus series to check, e.g. up{job=\"api\"}"`
+	PromExpect     string   `long:"prom-expect" description:"Expected value of the Prometheus series, optionally prefixed by ==, !=, <, <=, >, >="`
+	StatusFromJson string   `long:"status-from-json" description:"JSON key whose value drives the state instead of the HTTP status"`
+	StatusMap      string   `long:"status-map" description:"Mapping of JSON values to states, e.g. ok=0,warn=1,fail=2"`
+	GrpcWeb        string   `long:"grpc-web" description:"Call a gRPC-Web method (service/method) and check its grpc-status"`
+	GrpcWebBody    string   `long:"grpc-web-body" description:"Base64 encoded request message for --grpc-web"`
+	Sitemap        bool     `long:"validate-sitemap" description:"Validate response body as an XML sitemap"`
+	Robots         string   `long:"validate-robots" description:"Directives (csv) which robots.txt must contain, e.g. User-agent,Sitemap"`
+	HealthFormat   string   `long:"health-format" description:"Evaluate response body as a health document (actuator)"`
+	FailFast       bool     `long:"fail-fast" description:"Stop at the first failed assertion, which may not be the most severe one"`
+	Output         string   `long:"output" description:"Output format" choice:"text" choice:"json" default:"text"`
+	NoResponse     bool     `long:"report-no-response" description:"Report a connection closed before any response as status code 000"`
+	PerfdataOnly   bool     `long:"perfdata-only" description:"Print only the perfdata, the status is in the exit code"`
+	Syslog         bool     `long:"syslog" description:"Also write the result line to the local syslog"`
+	SyslogFacility string   `long:"syslog-facility" description:"Syslog facility, user, daemon or local0 to local7" default:"user"`
+	EmitStatusLine bool     `long:"emit-status-line" description:"Append an EXIT=<status> line to the output"`
+	Retries        int      `long:"retries" description:"Retry a CRITICAL check up to this many times, reporting the last attempt"`
+	RetryInterval  float64  `long:"retry-interval" description:"Seconds to wait between --retries attempts" default:"1"`
+	Repeat         int      `long:"repeat" description:"Run the check this many times, printing every result, and exit with the worst status"`
+	Interval       float64  `long:"interval" description:"Seconds to wait between --repeat runs" default:"1"`
+	ClientP12File  string   `long:"client-p12" description:"Client Certificate and Private Key in PKCS#12 File"`
+	ClientP12Pass  string   `long:"client-p12-pass" description:"Passphrase of the PKCS#12 File"`
 	Version        bool     `long:"version" description:"Print version"`
 }
 
@@ -48,19 +175,208 @@ const (
 	NagiosWarning  = 1
 	NagiosCritical = 2
 	NagiosUnknown  = 3
-	Version        = "0.2"
 )
 
+// Version and Commit are set at build time with
+// -ldflags "-X main.Version=... -X main.Commit=...".
+var (
+	Version = "0.2"
+	Commit  = ""
+)
+
+// severity orders Nagios states so that the worst one wins when several
+// checks disagree.
+var severity = map[int]int{
+	NagiosOk:       0,
+	NagiosWarning:  1,
+	NagiosUnknown:  2,
+	NagiosCritical: 3,
+}
+
+// Result accumulates the state and long output of all checks in a run.
+type Result struct {
+	Status   int
+	Messages []string
+	Perfdata []string
+	Checks   []Check
+}
+
+// Check is a single failed assertion.
+type Check struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Add records a message and raises the overall state if status is worse.
+func (r *Result) Add(status int, format string, a ...interface{}) {
+	if severity[status] > severity[r.Status] {
+		r.Status = status
+	}
+	message := fmt.Sprintf(format, a...)
+	r.Messages = append(r.Messages, message)
+	r.Checks = append(r.Checks, Check{Status: statusString(status), Message: message})
+}
+
+// stateByName maps a state name given on the command line to its status.
+var stateByName = map[string]int{
+	"ok":       NagiosOk,
+	"warning":  NagiosWarning,
+	"critical": NagiosCritical,
+	"unknown":  NagiosUnknown,
+}
+
+// statusMatches reports whether code matches an --expect token, either an
+// exact code, an inclusive range such as 200-299 or a class such as 2xx.
+func statusMatches(code int, expect string) bool {
+	expect = strings.TrimSpace(expect)
+	if len(expect) == 3 && strings.HasSuffix(strings.ToLower(expect), "xx") && expect[0] >= '1' && expect[0] <= '5' {
+		return code/100 == int(expect[0]-'0')
+	}
+	if from, to, found := strings.Cut(expect, "-"); found {
+		low, err1 := strconv.Atoi(from)
+		high, err2 := strconv.Atoi(to)
+		return err1 == nil && err2 == nil && code >= low && code <= high
+	}
+	return strconv.Itoa(code) == expect
+}
+
+// statusListMatches reports whether code matches any token of a csv list.
+func statusListMatches(code int, list string) bool {
+	for _, expect := range strings.Split(list, ",") {
+		if statusMatches(code, expect) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateStatusList checks every token of an --expect or --expect-not
+// list, so that a typo is reported instead of never matching.
+func validateStatusList(list string) error {
+	for _, token := range strings.Split(list, ",") {
+		token = strings.TrimSpace(token)
+		if len(token) == 3 && strings.HasSuffix(strings.ToLower(token), "xx") && token[0] >= '1' && token[0] <= '5' {
+			continue
+		}
+		from, to, found := strings.Cut(token, "-")
+		if !found {
+			to = from
+		}
+		low, err1 := parseStatusCode(from)
+		high, err2 := parseStatusCode(to)
+		if err1 != nil || err2 != nil || low > high {
+			return fmt.Errorf("invalid status code: %s", token)
+		}
+	}
+	return nil
+}
+
+// parseStatusCode parses a three digit status code.
+func parseStatusCode(s string) (int, error) {
+	code, err := strconv.Atoi(s)
+	if err != nil || len(s) != 3 || code < 100 || code > 599 {
+		return 0, fmt.Errorf("invalid status code: %s", s)
+	}
+	return code, nil
+}
+
+func statusString(status int) string {
+	switch status {
+	case NagiosOk:
+		return "OK"
+	case NagiosWarning:
+		return "WARNING"
+	case NagiosCritical:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// buildCommit returns the commit given at build time, falling back to the
+// VCS information recorded by the go command.
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// exit terminates with the given Nagios status. The sentinel line is for
+// wrappers which capture stdout but not the exit code.
+func exit(opts Options, status int) {
+	if opts.EmitStatusLine {
+		fmt.Printf("EXIT=%d\n", status)
+	}
+	os.Exit(status)
+}
+
+// fail reports a check which could not produce a response and exits.
+func fail(opts Options, status int, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	logResult(opts, status, fmt.Sprintf("HTTP %s - %s", statusString(status), message))
+	if opts.Output == "json" {
+		result := Result{}
+		result.Add(status, "%s", message)
+		printJSON(newJSONOutput(result, "", nil, 0, 0, nil))
+	} else if opts.PerfdataOnly {
+		// there is no perfdata, keep the reason out of stdout
+		fmt.Fprintf(os.Stderr, "HTTP %s - %s\n", statusString(status), message)
+	} else {
+		fmt.Printf("HTTP %s - %s\n", statusString(status), message)
+	}
+	exit(opts, status)
+}
+
 func genTlsConfig(opts Options) *tls.Config {
 	conf := &tls.Config{}
 
-	conf.InsecureSkipVerify = true
+	if opts.Insecure {
+		conf.InsecureSkipVerify = true
+	} else if opts.Vhost != "" {
+		// connecting by IP address, the certificate has to match the vhost
+		conf.ServerName = hostname(opts.Vhost)
+	}
+
+	if opts.CACert != "" {
+		pool, err := loadCertPool(opts.CACert)
+		if err != nil {
+			fail(opts, NagiosUnknown, "--ca-cert: %s", err)
+		}
+		conf.RootCAs = pool
+	}
+
+	if opts.LegacyCiphers {
+		conf.MinVersion = tls.VersionTLS10
+		conf.CipherSuites = legacyCipherSuites()
+	}
+
+	if opts.SslVersion != "" {
+		min, max, err := parseSSLVersion(opts.SslVersion)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+		conf.MinVersion, conf.MaxVersion = min, max
+	}
 
 	if opts.ClientCertFile != "" && opts.PrivateKeyFile != "" {
 		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.PrivateKeyFile)
 		if err != nil {
-			fmt.Printf("HTTP UNKNOWN - %s\n", err)
-			os.Exit(NagiosUnknown)
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.ClientP12File != "" {
+		cert, err := loadPKCS12(opts.ClientP12File, opts.ClientP12Pass)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
 		}
 		conf.Certificates = []tls.Certificate{cert}
 	}
@@ -76,33 +392,309 @@ func prettyPrintJSON(b []byte) ([]byte, error) {
 
 func main() {
 	var opts Options
-	var result_message string
+	var result Result
 	var host_header string
 	var additional_out []byte
 	scheme := "http"
-	_, err := flags.Parse(&opts)
+	parser := flags.NewParser(&opts, flags.Default)
+	_, err := parser.Parse()
 	if err != nil {
 		os.Exit(NagiosUnknown)
 	}
 
+	explicit := func(name string) bool {
+		option := parser.FindOptionByLongName(name)
+		return option.IsSet() && !option.IsSetDefault()
+	}
+
+	if opts.Unit == "ms" {
+		// thresholds are compared in seconds, which the defaults already are
+		if explicit("warn") {
+			opts.Warn /= 1000
+		}
+		if explicit("crit") {
+			opts.Crit /= 1000
+		}
+	}
+
 	if opts.Version {
-		fmt.Printf("check_http_go: %s\n", Version)
+		fmt.Printf("check_http_go: %s (commit %s, %s)\n", Version, buildCommit(), runtime.Version())
 		os.Exit(0)
 	}
 
+	if opts.PerfdataOnly && opts.Output == "json" {
+		fail(opts, NagiosUnknown, "--perfdata-only cannot be used with --output json")
+	}
+
+	if opts.Syslog && !validSyslogFacility(opts.SyslogFacility) {
+		fail(opts, NagiosUnknown, "unknown --syslog-facility %s", opts.SyslogFacility)
+	}
+
+	if opts.Repeat < 0 || opts.Interval < 0 {
+		fail(opts, NagiosUnknown, "--repeat and --interval must not be negative")
+	}
+	if opts.Repeat > 0 && os.Getenv(repeatChildEnv) == "" {
+		runRepeat(opts)
+	}
+	if opts.Retries < 0 || opts.RetryInterval < 0 {
+		fail(opts, NagiosUnknown, "--retries and --retry-interval must not be negative")
+	}
+	if opts.Retries > 0 && os.Getenv(retryChildEnv) == "" {
+		runRetries(opts)
+	}
+
+	if opts.HealthFormat != "" && opts.HealthFormat != "actuator" {
+		fail(opts, NagiosUnknown, "unsupported health format: %s", opts.HealthFormat)
+	}
+
+	if opts.NoBody {
+		if opts.CaptureFile != "" {
+			fail(opts, NagiosUnknown, "--no-body cannot be used with --capture-to-file")
+		}
+		if used := bodyOptions(opts); len(used) > 0 {
+			fail(opts, NagiosUnknown, "--no-body cannot be used with %s", strings.Join(used, ", "))
+		}
+	}
+
+	if strings.EqualFold(opts.Method, "HEAD") {
+		if used := bodyOptions(opts); len(used) > 0 {
+			fail(opts, NagiosUnknown, "HEAD responses have no body to check with %s", strings.Join(used, ", "))
+		}
+	}
+
+	if opts.ProbeMethod != "" {
+		if method := parser.FindOptionByLongName("method"); (method.IsSet() && !method.IsSetDefault()) || opts.Data != "" || opts.DataFile != "" || opts.GrpcWeb != "" {
+			fail(opts, NagiosUnknown, "--probe-method cannot be used with --method, --data or --grpc-web")
+		}
+		opts.Method = "OPTIONS"
+	}
+
+	if opts.NoCompress && opts.AcceptEncoding != "" {
+		fail(opts, NagiosUnknown, "--no-compression and --accept-encoding are mutually exclusive")
+	}
+
+	if opts.NoCompress && opts.WarnUncompress > 0 {
+		fail(opts, NagiosUnknown, "--no-compression cannot be used with --warn-uncompressed-threshold")
+	}
+
+	if opts.Proxy != "" && opts.PacURL != "" {
+		fail(opts, NagiosUnknown, "--proxy and --pac-url are mutually exclusive")
+	}
+
+	if (opts.Proxy != "" || opts.PacURL != "") && (opts.HeaderOrder != "" || opts.ConnectOnly) {
+		fail(opts, NagiosUnknown, "--proxy cannot be used with --expect-header-order or --connect-only")
+	}
+
+	if opts.OnRedirect != "" {
+		if opts.Follow && opts.OnRedirect != "follow" && opts.OnRedirect != "sticky" {
+			fail(opts, NagiosUnknown, "--onredirect %s cannot be used with --follow", opts.OnRedirect)
+		}
+		opts.Follow = opts.Follow || opts.OnRedirect == "follow" || opts.OnRedirect == "sticky"
+	}
+
+	if opts.Expect != "" {
+		if err := validateStatusList(opts.Expect); err != nil {
+			fail(opts, NagiosUnknown, "--expect: %s", err)
+		}
+	}
+
+	if opts.ExpectNot != "" {
+		if err := validateStatusList(opts.ExpectNot); err != nil {
+			fail(opts, NagiosUnknown, "--expect-not: %s", err)
+		}
+	}
+
+	// the challenge checked by --expect-auth-realm is not a client error
+	if opts.AuthRealm != "" && opts.Expect == "" {
+		opts.Expect = "401"
+	}
+
+	if opts.HeaderOrder != "" && opts.Follow {
+		fail(opts, NagiosUnknown, "--expect-header-order cannot be used with --follow")
+	}
+
+	if opts.MaxRedirs < 0 {
+		fail(opts, NagiosUnknown, "--max-redirs must not be negative")
+	}
+
+	if opts.RedirectWarn >= 0 && !opts.Follow {
+		fail(opts, NagiosUnknown, "--redirect-warn requires --follow")
+	}
+
+	if opts.RedirectBody >= 0 && opts.Follow {
+		fail(opts, NagiosUnknown, "--redirect-max-body cannot be used with --follow")
+	}
+
+	if opts.AssertInter != "" && !opts.Follow {
+		fail(opts, NagiosUnknown, "--assert-intermediate requires --follow")
+	}
+
+	for _, spec := range opts.ExpectHeader {
+		if name, _, _ := strings.Cut(spec, ":"); strings.TrimSpace(name) == "" {
+			fail(opts, NagiosUnknown, "invalid --expect-header `%s`, expected Name:Value or Name", spec)
+		}
+	}
+
+	if len(opts.JsonKey) != len(opts.JsonValue) {
+		fail(opts, NagiosUnknown, "--json-key and --json-value must be given the same number of times")
+	}
+
+	if opts.JsonOp != "" {
+		if len(opts.JsonKey) == 0 {
+			fail(opts, NagiosUnknown, "--json-op requires --json-key and --json-value")
+		}
+		for _, value := range opts.JsonValue {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				fail(opts, NagiosUnknown, "--json-value must be numeric with --json-op: %s", value)
+			}
+		}
+	}
+
+	if opts.JsonNested != (opts.JsonSubkey != "") || (opts.JsonNested && len(opts.JsonKey) != 1) {
+		fail(opts, NagiosUnknown, "--json-decode-nested and --json-subkey must be used together with a single --json-key")
+	}
+
+	if opts.RawPath && (opts.GrpcWeb != "" || strings.HasPrefix(opts.Uri, "//")) {
+		fail(opts, NagiosUnknown, "--raw-path cannot be used with --grpc-web or a uri starting with //")
+	}
+
+	if opts.Data != "" && opts.DataFile != "" {
+		fail(opts, NagiosUnknown, "--data and --data-file are mutually exclusive")
+	}
+
+	if opts.Auth != "" && opts.Bearer != "" {
+		fail(opts, NagiosUnknown, "--authorization and --bearer are mutually exclusive")
+	}
+
+	if opts.GrpcWeb != "" && (opts.Data != "" || opts.DataFile != "") {
+		fail(opts, NagiosUnknown, "--grpc-web and --data are mutually exclusive")
+	}
+
+	if opts.GrpcWeb != "" && strings.Count(strings.Trim(opts.GrpcWeb, "/"), "/") != 1 {
+		fail(opts, NagiosUnknown, "--grpc-web must be service/method")
+	}
+
+	if opts.ClientP12File != "" && opts.ClientCertFile != "" {
+		fail(opts, NagiosUnknown, "--client-p12 and --client-cert are mutually exclusive")
+	}
+
+	if (opts.PromMetric == "") != (opts.PromExpect == "") {
+		fail(opts, NagiosUnknown, "--prom-metric and --prom-expect must be used together")
+	}
+
+	var status_map map[string]int
+	if opts.StatusFromJson != "" {
+		if opts.StatusMap == "" {
+			fail(opts, NagiosUnknown, "--status-from-json requires --status-map")
+		}
+		status_map, err = parseStatusMap(opts.StatusMap)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	var body_regex *regexp.Regexp
+	if opts.Regex != "" {
+		body_regex, err = regexp.Compile(opts.Regex)
+		if err != nil {
+			fail(opts, NagiosUnknown, "invalid --regex: %s", err)
+		}
+	}
+
+	var expect_curve tls.CurveID
+	if opts.ExpectCurve != "" {
+		expect_curve, err = parseCurve(opts.ExpectCurve)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	var header_thresholds []headerThreshold
+	for _, spec := range opts.HdrThreshold {
+		t, err := parseHeaderThreshold(spec)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+		header_thresholds = append(header_thresholds, t)
+	}
+
+	cookie_patterns, err := parseCookiePatterns(opts.CookieValue)
+	if err != nil {
+		fail(opts, NagiosUnknown, "%s", err)
+	}
+	cookies, err := parseCookies(opts.Cookies)
+	if err != nil {
+		fail(opts, NagiosUnknown, "%s", err)
+	}
+
+	if opts.ExpectChanged && opts.ExpectSame {
+		fail(opts, NagiosUnknown, "--expect-changed and --expect-unchanged are mutually exclusive")
+	}
+
+	var size_tolerance int64
+	if opts.ExpectSize > 0 {
+		size_tolerance, err = parseSizeTolerance(opts.SizeTolerance, opts.ExpectSize)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	var cert_warn, cert_crit int
+	if opts.CertDays != "" {
+		cert_warn, cert_crit, err = parseCertDays(opts.CertDays)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	var chain_warn, chain_crit int
+	if opts.ChainDays != "" {
+		chain_warn, chain_crit, err = parseCertDays(opts.ChainDays)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	var if_modified_since time.Time
+	if opts.IfModSince != "" {
+		if_modified_since, err = parseHTTPTime(opts.IfModSince)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	var url_user *url.Userinfo
+	if opts.Url != "" {
+		url_user, err = applyURL(&opts, opts.Url)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
 	if opts.Ipaddr == "" && opts.Vhost != "" {
 		opts.Ipaddr = opts.Vhost
 	}
 	if opts.Ipaddr == "" {
-		os.Exit(NagiosUnknown)
+		exit(opts, NagiosUnknown)
 	}
-	host_header = opts.Ipaddr
+	opts.Ipaddr = strings.Trim(opts.Ipaddr, "[]")
+	host_header = hostLiteral(opts.Ipaddr)
 	if opts.Vhost != "" {
-		host_header = opts.Vhost
+		host_header = hostLiteral(opts.Vhost)
+	}
+	if opts.Ssl {
+		scheme = "https"
+	} else if opts.CertDays != "" {
+		fail(opts, NagiosUnknown, "--certificate requires https, there is no certificate on plain HTTP")
+	}
+	if opts.H2c && (opts.Ssl || opts.Proxy != "" || opts.PacURL != "" || opts.HeaderOrder != "") {
+		fail(opts, NagiosUnknown, "--h2c cannot be used with https, --proxy or --expect-header-order")
+	}
+	if opts.Http2 == "off" && opts.H2c {
+		fail(opts, NagiosUnknown, "--http2 off cannot be used with --h2c")
 	}
 	if opts.Port == 0 {
 		if opts.Ssl {
-			scheme = "https"
 			opts.Port = 443
 		} else {
 			opts.Port = 80
@@ -111,130 +703,771 @@ func main() {
 
 	// https://golang.org/pkg/crypto/tls/#Config
 	tr := &http.Transport{
-		TLSClientConfig: genTlsConfig(opts),
+		TLSClientConfig:        genTlsConfig(opts),
+		DisableCompression:     opts.NoCompress,
+		MaxResponseHeaderBytes: opts.MaxHeaderBytes,
+	}
+
+	// without --connect-timeout connecting may take the whole timeout
+	connect_timeout := opts.Timeout
+	if opts.ConnTimeout > 0 {
+		connect_timeout = opts.ConnTimeout
+	}
+	dialer := &net.Dialer{Timeout: time.Duration(connect_timeout) * time.Second}
+	tr.DialContext = dialer.DialContext
+	if opts.DnsTimeout > 0 || opts.DnsRetries > 0 {
+		tr.DialContext = dnsDialContext(dialer, time.Duration(opts.DnsTimeout*float64(time.Second)), opts.DnsRetries)
+	}
+
+	if opts.SshJump != "" {
+		client, err := sshJump(opts.SshJump, opts.SshKey, opts.SshKnownHosts, time.Duration(opts.Timeout)*time.Second)
+		if err != nil {
+			fail(opts, NagiosUnknown, "ssh jump host %s: %s", opts.SshJump, err)
+		}
+		defer client.Close()
+		tr.DialContext = sshDialContext(client)
+	}
+
+	if opts.PacURL != "" {
+		script, err := fetchPAC(opts.PacURL, time.Duration(opts.Timeout)*time.Second)
+		if err != nil {
+			fail(opts, NagiosUnknown, "PAC: %s", err)
+		}
+		target := scheme + "://" + host_header + opts.Uri
+		found, err := evalPAC(script, target, hostname(host_header), time.Duration(opts.Timeout)*time.Second)
+		if err != nil {
+			fail(opts, NagiosUnknown, "PAC evaluation failed: %s", err)
+		}
+		opts.Proxy, err = pacProxy(found)
+		if err != nil {
+			fail(opts, NagiosUnknown, "PAC: %s", err)
+		}
+	}
+
+	if opts.Proxy != "" {
+		proxy, err := url.Parse(opts.Proxy)
+		if err != nil || proxy.Host == "" {
+			fail(opts, NagiosUnknown, "invalid proxy URL: %s", opts.Proxy)
+		}
+		tr.Proxy = http.ProxyURL(proxy)
+		if proxy.Scheme == "https" {
+			conf, err := proxyTLSConfig(proxy, opts.ProxyCAFile, opts.Insecure)
+			if err != nil {
+				fail(opts, NagiosUnknown, "%s", err)
+			}
+			tr.DialTLSContext = proxyDialTLS(tr.DialContext, conf)
+		} else if opts.ProxyCAFile != "" {
+			fail(opts, NagiosUnknown, "--proxy-ca-file requires an https proxy")
+		}
+	} else if opts.ProxyCAFile != "" {
+		fail(opts, NagiosUnknown, "--proxy-ca-file requires --proxy")
+	} else if opts.PacURL == "" {
+		// a PAC file saying DIRECT wins over the environment
+		tr.Proxy = environmentProxy(opts)
+	}
+
+	var header_recorder *headerRecorder
+	if opts.HeaderOrder != "" {
+		header_recorder = &headerRecorder{}
+		tr.DialContext, tr.DialTLSContext = recordingDialers(tr.DialContext, tr.TLSClientConfig, header_recorder)
+	}
+
+	if opts.ConnectOnly {
+		runConnectOnly(opts, tr.DialContext, tr.TLSClientConfig, host_header)
 	}
 
 	// https://github.com/golang/go/issues/17051
 	// https://qiita.com/catatsuy/items/ee4fc094c6b9c39ee08f
-	if err := http2.ConfigureTransport(tr); err != nil {
+	if opts.Http2 == "off" {
+		tr.ForceAttemptHTTP2 = false
+		tr.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	} else if err := http2.ConfigureTransport(tr); err != nil {
 		log.Fatalf("Failed to configure h2 transport: %s", err)
 	}
 
+	var redirects []redirectHop
 	c := &http.Client{
-		Timeout: time.Duration(opts.Timeout) * time.Second,
-		// https://jonathanmh.com/tracing-preventing-http-redirects-golang/
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Transport: tr,
+		Timeout:       time.Duration(opts.Timeout) * time.Second,
+		CheckRedirect: redirectPolicy(opts.Follow, opts.OnRedirect == "sticky", opts.MaxRedirs, &redirects),
+		Transport:     tr,
+	}
+	if opts.Follow {
+		// cookies set by a redirect are sent to where it leads, as after a login
+		c.Jar, _ = cookiejar.New(nil)
+	}
+	if opts.H2c {
+		c.Transport = h2cTransport(tr.DialContext, opts.MaxHeaderBytes)
 	}
 
-	url_str := scheme + "://" + opts.Ipaddr + ":" + strconv.Itoa(opts.Port) + opts.Uri
+	url_str := targetURL(scheme, opts.Ipaddr, opts.Port, opts.Uri)
 
-	values := url.Values{}
+	var body []byte
+	if opts.Data != "" {
+		data, err := expandTemplate(opts.Data, time.Now())
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+		body = []byte(data)
+	}
+	if opts.DataFile != "" {
+		body, err = ioutil.ReadFile(opts.DataFile)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
 
-	req, err := http.NewRequest(opts.Method, url_str, strings.NewReader(values.Encode()))
+	if opts.GrpcWeb != "" {
+		msg, err := base64.StdEncoding.DecodeString(opts.GrpcWebBody)
+		if err != nil {
+			fail(opts, NagiosUnknown, "invalid --grpc-web-body: %s", err)
+		}
+		body = grpcWebFrame(msg)
+		opts.Method = "POST"
+		url_str = strings.TrimSuffix(url_str, "/") + "/" + strings.TrimPrefix(opts.GrpcWeb, "/")
+	}
+
+	req, err := http.NewRequest(opts.Method, url_str, bytes.NewReader(body))
 	if err != nil {
-		fmt.Printf("HTTP UNKNOWN - %s\n", err)
-		os.Exit(NagiosUnknown)
+		fail(opts, NagiosUnknown, "%s", err)
+	}
+	if opts.RawPath {
+		setRawPath(req, opts.Uri)
+	}
+
+	if opts.Data != "" || opts.DataFile != "" {
+		req.Header.Set("Content-Type", dataContentType(body))
+	}
+	if opts.GrpcWeb != "" {
+		req.Header.Set("Content-Type", "application/grpc-web+proto")
+		req.Header.Set("X-Grpc-Web", "1")
 	}
 
 	req.Host = host_header
+	if url_user != nil {
+		password, _ := url_user.Password()
+		req.SetBasicAuth(url_user.Username(), password)
+	}
+	if opts.Auth != "" {
+		auth := strings.SplitN(opts.Auth, ":", 2)
+		if len(auth) != 2 {
+			fail(opts, NagiosUnknown, "--authorization must be user:password")
+		}
+		req.SetBasicAuth(auth[0], auth[1])
+	}
+	if opts.Bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Bearer)
+	}
 	req.Header.Set("User-Agent", opts.UserAgent)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if opts.NoCompress {
+		req.Header.Set("Accept-Encoding", "identity")
+	}
+	if opts.AcceptEncoding != "" {
+		// set by hand, the transport leaves the body encoded
+		req.Header.Set("Accept-Encoding", opts.AcceptEncoding)
+	}
 
+	overridden := map[string]bool{}
 	for _, header := range opts.Headers {
-		hdr := strings.SplitN(header, ": ", 2)
-		req.Header.Set(hdr[0], hdr[1])
+		hdr := strings.SplitN(header, ":", 2)
+		if len(hdr) != 2 || strings.TrimSpace(hdr[0]) == "" {
+			fail(opts, NagiosUnknown, "invalid header `%s`, expected Name: value", header)
+		}
+		name := http.CanonicalHeaderKey(strings.TrimSpace(hdr[0]))
+		// the first occurrence replaces a default such as User-Agent
+		if !overridden[name] {
+			req.Header.Del(name)
+			overridden[name] = true
+		}
+		req.Header.Add(name, strings.TrimSpace(hdr[1]))
+	}
+
+	if opts.IfNoneMatch != "" {
+		req.Header.Set("If-None-Match", opts.IfNoneMatch)
+	}
+	if !if_modified_since.IsZero() {
+		req.Header.Set("If-Modified-Since", if_modified_since.UTC().Format(http.TimeFormat))
+	}
+
+	if opts.HeaderFile != "" {
+		header, err := readHeaderFile(opts.HeaderFile)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+		for name, values := range header {
+			req.Header.Del(name)
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+	}
+
+	if opts.HmacSecret != "" {
+		secret, err := ioutil.ReadFile(opts.HmacSecret)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+		secret = bytes.TrimRight(secret, "\r\n")
+		if err := signRequest(req, body, secret, opts.HmacAlgo, opts.HmacHeader, opts.HmacTsHeader, opts.HmacString, time.Now()); err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	var first_byte time.Time
+	if opts.Throughput || opts.MaxBodyTime > 0 {
+		req = traceFirstByte(req, &first_byte)
+	}
+	var phases phaseTimes
+	if opts.Trace {
+		req = tracePhases(req, &phases)
 	}
 
 	t1 := time.Now()
 
 	resp, err := c.Do(req)
 	if err != nil {
-		fmt.Printf("HTTP CRITICAL - %s\n", err)
-		os.Exit(NagiosCritical)
+		if isDNSError(err) {
+			fail(opts, NagiosCritical, "DNS lookup failed: %s", err)
+		}
+		if msg := protocolMismatch(err, opts.Ssl, opts.Port); msg != "" {
+			fail(opts, NagiosCritical, "%s", msg)
+		}
+		if isConnectTimeout(err) {
+			fail(opts, stateByName[opts.TimeoutState], "connection timed out: %s", err)
+		}
+		if isTimeout(err) {
+			fail(opts, stateByName[opts.TimeoutState], "request timed out after %ds: %s", opts.Timeout, err)
+		}
+		if opts.H2c && isH2CUnsupported(err) {
+			fail(opts, NagiosUnknown, "server does not support h2c: %s", err)
+		}
+		if opts.MaxHeaderBytes > 0 && isHeaderTooLarge(err) {
+			fail(opts, NagiosCritical, "response header larger than %d bytes: %s", opts.MaxHeaderBytes, err)
+		}
+		if cert := expiredCertificate(err); cert != nil && opts.CertDays != "" {
+			failCertExpired(opts, url_str, cert, cert_warn, cert_crit, time.Since(t1))
+		}
+		if opts.SslVersion != "" {
+			if msg := versionMismatch(err, opts.SslVersion); msg != "" {
+				fail(opts, NagiosCritical, "%s", msg)
+			}
+		}
+		if tr.Proxy != nil && isProxyConnectError(err) {
+			proxy, _ := tr.Proxy(req)
+			fail(opts, NagiosCritical, "proxy %s unreachable, the target was not contacted: %s", proxy.Redacted(), err)
+		}
+		if opts.NoResponse && isNoResponse(err) {
+			failNoResponse(opts, url_str, err, time.Since(t1))
+		}
+		fail(opts, NagiosCritical, "%s", err)
 	}
 
 	defer resp.Body.Close()
-	buf, err := ioutil.ReadAll(resp.Body)
+	var counter *bodyCounter
+	if opts.Throughput {
+		counter = &bodyCounter{ReadCloser: resp.Body}
+		resp.Body = counter
+	}
+	var capture *captureWriter
+	if opts.CaptureFile != "" {
+		capture, err = createCapture(opts.CaptureFile)
+		if err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+	// a body the transport did not decompress is buffered to capture it decoded
+	encoded := resp.Header.Get("Content-Encoding") != "" && !resp.Uncompressed
+
+	var buf []byte
+	var size int64
+	if opts.NoBody {
+		if resp.ContentLength > 0 {
+			size = resp.ContentLength
+		}
+	} else if needBody(opts) || (!opts.Ssl && resp.StatusCode == 400) || (capture != nil && encoded) {
+		// a 400 on plain HTTP may explain that the port expects TLS
+		buf, err = ioutil.ReadAll(resp.Body)
+		size = int64(len(buf))
+	} else if capture != nil {
+		size, err = io.Copy(capture, resp.Body)
+		if capture.err != nil {
+			capture.Abort()
+			fail(opts, NagiosUnknown, "%s", capture.err)
+		}
+	} else {
+		// nothing inspects the body, so only count it
+		size, err = io.Copy(ioutil.Discard, resp.Body)
+	}
 	if err != nil {
-		fmt.Printf("HTTP CRITICAL - %s\n", err)
-		os.Exit(NagiosCritical)
+		// a partly read body does not replace an earlier capture
+		if capture != nil {
+			capture.Abort()
+		}
+		if isTimeout(err) {
+			fail(opts, stateByName[opts.TimeoutState], "request timed out after %ds reading the body: %s", opts.Timeout, err)
+		}
+		fail(opts, NagiosCritical, "%s", err)
 	}
 
 	t2 := time.Now()
 	diff := t2.Sub(t1)
-
-	status_text := strconv.Itoa(resp.StatusCode)
-	size := len(buf)
-
-	if opts.Verbose {
-		fmt.Print(string(buf))
+	if opts.Trace {
+		result.Perfdata = append(result.Perfdata, phases.perfdata(t1)...)
+	}
+	if opts.Throughput {
+		throughput := throughputPerfdata(t1, first_byte, t2, counter)
+		if opts.Trace {
+			// ttfb is already there
+			throughput = throughput[1:]
+		}
+		result.Perfdata = append(result.Perfdata, throughput...)
+	}
+	if opts.MaxBodyTime > 0 {
+		result.Perfdata = append(result.Perfdata, checkBodyTime(first_byte, t2, opts.MaxBodyTime, &result))
 	}
 
-	nagios_status := NagiosOk
+	// only what inspects the body needs it decoded, -v shows it as received
+	need_decoded := len(bodyOptions(opts)) > 0 || opts.StateFile != "" || capture != nil
+	undecodable := false
+	if buf != nil && !resp.Uncompressed && (need_decoded || opts.NoCompress || opts.AcceptEncoding != "") {
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+			decoded, err := decodeBody(encoding, buf)
+			var unsupported unsupportedCodingError
+			if errors.As(err, &unsupported) {
+				if need_decoded {
+					if capture != nil {
+						capture.Abort()
+					}
+					fail(opts, NagiosUnknown, "cannot check a body with content encoding %s", unsupported.coding)
+				}
+				// only the sizes asked for it, the wire size is all there is
+				undecodable = true
+			} else if err != nil {
+				if capture != nil {
+					capture.Abort()
+				}
+				fail(opts, NagiosCritical, "failed to decode %s body: %s", encoding, err)
+			} else {
+				buf = decoded
+				if opts.NoCompress {
+					// the server ignored identity, report what it produced
+					size = int64(len(buf))
+				}
+			}
+		}
+	}
+	if opts.AcceptEncoding != "" && buf != nil && !undecodable {
+		result.Perfdata = append(result.Perfdata, fmt.Sprintf("decoded_size=%dB;;;0", len(buf)))
+	}
 
-	if opts.Expect == "" {
-		if resp.StatusCode >= 500 {
-			nagios_status = NagiosCritical
-			result_message = fmt.Sprintf("Unexpected http status code: %d", resp.StatusCode)
-		} else if resp.StatusCode >= 400 {
-			nagios_status = NagiosWarning
-			result_message = fmt.Sprintf("Unexpected http status code: %d", resp.StatusCode)
+	if capture != nil {
+		if buf != nil {
+			capture.Write(buf)
 		}
-	} else {
-		nagios_status = NagiosWarning
-		for _, expect := range strings.Split(opts.Expect, ",") {
-			if status_text == expect {
-				nagios_status = NagiosOk
+		if err := capture.Commit(); err != nil {
+			fail(opts, NagiosUnknown, "%s", err)
+		}
+	}
+
+	if opts.Verbose {
+		if (opts.MinChainLength > 0 || opts.ChainLength > 0) && resp.TLS != nil {
+			fmt.Printf("certificate chain length: %d\n", len(resp.TLS.PeerCertificates))
+			if opts.ChainLength > 0 {
+				for i, cert := range resp.TLS.PeerCertificates {
+					fmt.Printf("certificate #%d: %s\n", i, cert.Subject)
+				}
 			}
 		}
-		if nagios_status == NagiosWarning {
-			result_message = fmt.Sprintf("Unexpected http status code: %d", resp.StatusCode)
+		for _, svc := range parseAltSvc(resp.Header.Values("Alt-Svc")) {
+			fmt.Printf("alt-svc: %s\n", svc)
+		}
+		shown := buf
+		if opts.Bearer != "" {
+			// an echo service must not leak the token into the output
+			shown = bytes.ReplaceAll(shown, []byte(opts.Bearer), []byte("[redacted]"))
+		}
+		if opts.VerboseLimit > 0 && len(shown) > opts.VerboseLimit {
+			fmt.Printf("%s\n... (truncated, %d of %d bytes shown)\n", shown[:opts.VerboseLimit], opts.VerboseLimit, len(shown))
+		} else {
+			fmt.Print(string(shown))
 		}
 	}
 
-	if opts.JsonKey != "" && opts.JsonValue != "" {
-		// https://stackoverflow.com/questions/27689058/convert-string-to-interface
-		t := strings.Split(opts.JsonKey, ".")
-		s := make([]interface{}, len(t))
-		for i, v := range t {
-			s[i] = v
-		}
-		// https://reformatcode.com/code/json/taking-a-json-string-unmarshaling-it-into-a-mapstringinterface-editing-and-marshaling-it-into-a-byte-seems-more-complicated-then-it-should-be
-		var d map[string]interface{}
-		json.Unmarshal(buf, &d)
-		// https://qiita.com/hnakamur/items/c3560a4b780487ef6065
-		v, _ := dyno.Get(d, s...)
-		if v != opts.JsonValue {
-			nagios_status = NagiosCritical
-			result_message = fmt.Sprintf("`%s` is not `%s`", opts.JsonKey, opts.JsonValue)
+	// assertions run in order, --fail-fast stops at the first failed one
+	// the fingerprint is recorded once the final status is known
+	var state *fingerprint
+	checks := []func(){
+		func() {
+			if resp.StatusCode == http.StatusNotModified && (opts.IfNoneMatch != "" || opts.IfModSince != "") {
+				result.Messages = append(result.Messages, conditionalNote(resp.Header, opts.IfNoneMatch, if_modified_since))
+			}
+		},
+		func() {
+			if !opts.Ssl && buf != nil {
+				checkPlainHTTPToTLS(resp.StatusCode, buf, opts.Port, &result)
+			}
+		},
+		func() {
+			if opts.StatusFromJson != "" {
+				checkStatusFromJSON(buf, opts.StatusFromJson, status_map, &result)
+			} else if !opts.Follow && opts.OnRedirect != "" && isRedirect(resp.StatusCode) {
+				if state := stateByName[opts.OnRedirect]; state != NagiosOk {
+					result.Add(state, "Redirected with %d to %s", resp.StatusCode, resp.Header.Get("Location"))
+				}
+			} else if opts.Expect == "" {
+				if resp.StatusCode >= 500 {
+					result.Add(NagiosCritical, "Unexpected http status code: %d", resp.StatusCode)
+				} else if resp.StatusCode >= 400 || (opts.Strict2xx && (resp.StatusCode < 200 || resp.StatusCode >= 300)) {
+					result.Add(NagiosWarning, "Unexpected http status code: %d", resp.StatusCode)
+				}
+			} else {
+				if !statusListMatches(resp.StatusCode, opts.Expect) {
+					result.Add(NagiosWarning, "Unexpected http status code: %d", resp.StatusCode)
+				}
+			}
+		},
+		func() {
+			if opts.ExpectNot == "" {
+				return
+			}
+			if !statusListMatches(resp.StatusCode, opts.ExpectNot) {
+				return
+			}
+			if statusListMatches(resp.StatusCode, opts.Expect) {
+				result.Add(NagiosCritical, "Forbidden http status code: %d (listed in both --expect and --expect-not, --expect-not wins)", resp.StatusCode)
+			} else {
+				result.Add(NagiosCritical, "Forbidden http status code: %d", resp.StatusCode)
+			}
+		},
+		func() {
+			if len(opts.JsonKey) > 0 {
+				// https://reformatcode.com/code/json/taking-a-json-string-unmarshaling-it-into-a-mapstringinterface-editing-and-marshaling-it-into-a-byte-seems-more-complicated-then-it-should-be
+				var d map[string]interface{}
+				json.Unmarshal(buf, &d)
+				for i, key := range opts.JsonKey {
+					checkJsonPath(d, key, opts.JsonValue[i], opts.JsonOp, opts.JsonSubkey, &result)
+				}
+				additional_out, err = prettyPrintJSON(buf)
+			}
+		},
+		func() {
+			if opts.ExpectRedirect != "" {
+				checkRedirectLocation(resp, opts.ExpectRedirect, &result)
+			}
+		},
+		func() {
+			if opts.NoRedirect {
+				checkNoRedirect(resp, redirects, &result)
+			}
+		},
+		func() {
+			if opts.RedirectBody >= 0 {
+				checkRedirectBody(resp.StatusCode, size, opts.RedirectBody, &result)
+			}
+		},
+		func() {
+			if opts.RedirectWarn >= 0 {
+				checkRedirectCount(redirects, opts.RedirectWarn, &result)
+			}
+		},
+		func() {
+			if opts.AssertInter != "" {
+				checkIntermediate(redirects, splitList([]string{opts.AssertInter}), &result)
+			}
+		},
+		func() {
+			if opts.String != "" {
+				checkString(buf, opts.String, &result)
+			}
+		},
+		func() {
+			if body_regex != nil {
+				checkRegex(buf, body_regex, &result)
+			}
+		},
+		func() {
+			if len(opts.Forbid) > 0 {
+				checkForbidden(buf, splitPatterns(opts.Forbid), &result)
+			}
+		},
+		func() {
+			if opts.ForbidCtrl {
+				checkControlChars(buf, stateByName[opts.ForbidCtrlSt], &result)
+			}
+		},
+		func() {
+			if len(opts.ForbidCookie) > 0 {
+				checkForbiddenCookies(resp.Cookies(), opts.ForbidCookie, stateByName[opts.ForbidCookieSt], &result)
+			}
+		},
+		func() {
+			if len(cookie_patterns) > 0 {
+				checkCookieValues(resp.Cookies(), cookie_patterns, opts.RedactCookie, &result)
+			}
+		},
+		func() {
+			if opts.MinSize > 0 || opts.MaxSize > 0 {
+				checkSizeRange(size, opts.MinSize, opts.MaxSize, &result)
+			}
+		},
+		func() {
+			if opts.ExpectSize > 0 {
+				checkExpectedSize(size, opts.ExpectSize, size_tolerance, &result)
+			}
+		},
+		func() {
+			if opts.VaryEncoding {
+				checkVaryEncoding(resp, &result)
+			}
+		},
+		func() {
+			if opts.WarnUncompress > 0 {
+				checkUncompressed(resp, size, opts.WarnUncompress, &result)
+			}
+		},
+		func() {
+			if opts.AuthRealm != "" {
+				checkAuthRealm(resp, opts.AuthRealm, &result)
+			}
+		},
+		func() {
+			for _, t := range header_thresholds {
+				checkHeaderThreshold(resp.Header, t, &result)
+			}
+		},
+		func() {
+			if opts.MaxHeaderBytes > 0 {
+				checkHeaderBytes(resp, opts.MaxHeaderBytes, &result)
+			}
+		},
+		func() {
+			for _, spec := range opts.ExpectHeader {
+				checkExpectedHeader(resp.Header, spec, &result)
+			}
+		},
+		func() {
+			if len(opts.RequireHeaders) > 0 {
+				checkRequiredHeaders(resp.Header, splitList(opts.RequireHeaders), stateByName[opts.RequireHdrSt], &result)
+			}
+		},
+		func() {
+			if opts.ReportAltSvc {
+				services := parseAltSvc(resp.Header.Values("Alt-Svc"))
+				if len(services) == 0 {
+					result.Messages = append(result.Messages, "Alt-Svc: none")
+				}
+				for _, svc := range services {
+					result.Messages = append(result.Messages, fmt.Sprintf("Alt-Svc: %s", svc))
+				}
+			}
+		},
+		func() {
+			if opts.ExpectAltSvc != "" {
+				checkAltSvc(parseAltSvc(resp.Header.Values("Alt-Svc")), opts.ExpectAltSvc, &result)
+			}
+		},
+		func() {
+			if opts.ProbeMethod != "" {
+				checkAllowsMethod(resp.Header, opts.ProbeMethod, &result)
+			}
+		},
+		func() {
+			if len(opts.ExpectAllow) > 0 {
+				checkAllow(resp.Header, splitList(opts.ExpectAllow), &result)
+			}
+		},
+		func() {
+			if opts.HeaderOrder != "" {
+				checkHeaderOrder(header_recorder.names(), splitList([]string{opts.HeaderOrder}), &result)
+			}
+		},
+		func() {
+			if opts.MaxClockSkew > 0 {
+				checkClockSkew(resp.Header, t1, t2, opts.MaxClockSkew, &result)
+			}
+		},
+		func() {
+			if opts.MaxCacheAge > 0 {
+				checkCacheAge(resp.Header, opts.MaxCacheAge, opts.AgeMissing == "unknown", &result)
+			}
+		},
+		func() {
+			if opts.StateFile != "" {
+				current := newFingerprint(buf, resp.Header.Get("ETag"), size, opts.NoBody)
+				if checkStateFile(opts.StateFile, current, opts.ExpectChanged, opts.ExpectSame, &result) {
+					state = &current
+				}
+			}
+		},
+		func() {
+			if opts.PromMetric != "" {
+				checkPrometheus(buf, opts.PromMetric, opts.PromExpect, &result)
+			}
+		},
+		func() {
+			if opts.GrpcWeb != "" {
+				checkGrpcWeb(resp.Header, buf, &result)
+			}
+		},
+		func() {
+			if opts.Sitemap {
+				checkSitemap(buf, &result)
+			}
+		},
+		func() {
+			if opts.Robots != "" {
+				checkRobots(buf, splitList([]string{opts.Robots}), &result)
+			}
+		},
+		func() {
+			if opts.HealthFormat != "" {
+				checkHealth(buf, &result)
+			}
+		},
+		func() {
+			if opts.MinChainLength > 0 {
+				if resp.TLS == nil {
+					result.Add(NagiosUnknown, "certificate chain length requires a TLS connection")
+				} else {
+					if len(resp.TLS.PeerCertificates) < opts.MinChainLength {
+						result.Add(NagiosCritical, "server presented %d certificates, expected at least %d", len(resp.TLS.PeerCertificates), opts.MinChainLength)
+					}
+				}
+			}
+		},
+		func() {
+			if opts.ChainLength > 0 {
+				if resp.TLS == nil {
+					result.Add(NagiosUnknown, "certificate chain length requires a TLS connection")
+				} else if len(resp.TLS.PeerCertificates) != opts.ChainLength {
+					result.Add(NagiosCritical, "server presented %d certificates, expected exactly %d", len(resp.TLS.PeerCertificates), opts.ChainLength)
+				}
+			}
+		},
+		func() {
+			if opts.CertDays != "" {
+				if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+					result.Add(NagiosUnknown, "-C requires a TLS connection")
+				} else {
+					checkCertExpiry(resp.TLS.PeerCertificates[0], cert_warn, cert_crit, &result)
+				}
+			}
+		},
+		func() {
+			if len(opts.PinSPKI) > 0 {
+				if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+					result.Add(NagiosUnknown, "public key pinning requires a TLS connection")
+				} else {
+					checkSPKIPins(resp.TLS.PeerCertificates[0], splitList(opts.PinSPKI), &result)
+				}
+			}
+		},
+		func() {
+			if opts.ChainDays != "" {
+				if resp.TLS == nil {
+					result.Add(NagiosUnknown, "certificate chain expiry requires a TLS connection")
+				} else {
+					checkChainExpiry(resp.TLS.PeerCertificates, chain_warn, chain_crit, &result)
+				}
+			}
+		},
+		func() {
+			if opts.Http2 == "force" && resp.ProtoMajor != 2 {
+				result.Add(NagiosCritical, "response served over %s, --http2 force requires HTTP/2", resp.Proto)
+			}
+		},
+		func() {
+			if opts.ForbidHttp10 && resp.ProtoMajor == 1 && resp.ProtoMinor == 0 {
+				result.Add(NagiosWarning, "response served over %s", resp.Proto)
+			}
+		},
+		func() {
+			if opts.AdviseCert && resp.TLS != nil {
+				if err := verifyChain(resp.TLS.PeerCertificates, hostname(host_header), tr.TLSClientConfig.RootCAs); err != nil {
+					result.Messages = append(result.Messages, fmt.Sprintf("WARNING: certificate would fail verification: %s", err))
+				}
+			}
+		},
+		func() {
+			if opts.LegacyCiphers && resp.TLS != nil {
+				result.Messages = append(result.Messages, fmt.Sprintf("WARNING: legacy crypto permitted, negotiated %s %s", tls.VersionName(resp.TLS.Version), tls.CipherSuiteName(resp.TLS.CipherSuite)))
+			}
+		},
+		func() {
+			if opts.RequirePfs {
+				checkForwardSecrecy(resp.TLS, stateByName[opts.RequirePfsSt], &result)
+			}
+		},
+		func() {
+			if opts.ExpectCurve != "" {
+				checkCurve(resp.TLS, expect_curve, &result)
+			}
+		},
+	}
+	for _, check := range checks {
+		if opts.FailFast && result.Status != NagiosOk {
+			break
 		}
-		additional_out, err = prettyPrintJSON(buf)
+		check()
 	}
 
-	if nagios_status == NagiosOk {
+	// with -C only the certificate is checked unless thresholds are given
+	timed := opts.CertDays == "" || explicit("warn") || explicit("crit")
+	if result.Status == NagiosOk && timed {
 		if diff.Seconds() > opts.Crit {
-			nagios_status = NagiosCritical
-			result_message = fmt.Sprintf("response time %3.fs exceeded critical threshold %.3fs", diff.Seconds(), opts.Crit)
+			result.Add(NagiosCritical, "response time %s exceeded critical threshold %s", formatTime(diff.Seconds(), opts.Unit), formatTime(opts.Crit, opts.Unit))
 		} else if diff.Seconds() > opts.Warn {
-			nagios_status = NagiosWarning
-			result_message = fmt.Sprintf("response time %3.fs exceeded warning threshold %.3fs", diff.Seconds(), opts.Warn)
+			result.Add(NagiosWarning, "response time %s exceeded warning threshold %s", formatTime(diff.Seconds(), opts.Unit), formatTime(opts.Warn, opts.Unit))
+		}
+	}
+
+	// an attempt which is retried leaves the state to the one reported
+	if state != nil && finalAttempt(opts, result.Status) {
+		if err := writeStateFile(opts.StateFile, *state); err != nil {
+			result.Add(NagiosUnknown, "state file: %s", err)
+		}
+	}
+
+	if opts.VerboseOk && result.Status == NagiosOk {
+		result.Messages = append(result.Messages, okSummary(opts, resp, diff, timed))
+	}
+
+	if opts.Ssl && resp.TLS != nil {
+		// the JSON output has these in its tls object
+		if opts.Output != "json" {
+			result.Messages = append(result.Messages, tlsVersionString(resp.TLS.Version)+" "+tls.CipherSuiteName(resp.TLS.CipherSuite))
+		}
+		if opts.TlsPerfdata {
+			result.Perfdata = append(result.Perfdata, fmt.Sprintf("tls_version=%s;;;0", strings.TrimPrefix(tlsVersionString(resp.TLS.Version), "TLS")))
 		}
 	}
 
-	result_str := "OK"
-	if nagios_status == NagiosWarning {
-		result_str = "WARNING"
-	} else if nagios_status == NagiosCritical {
-		result_str = "CRITICAL"
+	// the default perfdata stays threshold-free, as it was before --unit
+	time_thresholds := timed && (explicit("unit") || explicit("warn") || explicit("crit"))
+	perfdata := append([]string{
+		timePerfdata(diff.Seconds(), opts, time_thresholds),
+		fmt.Sprintf("size=%dB;;;0", size),
+	}, result.Perfdata...)
+	status_line := fmt.Sprintf("HTTP %s: %s %s - %d bytes in %s response time |%s", statusString(result.Status), resp.Proto, resp.Status, size, responseTime(diff.Seconds(), opts.Unit), strings.Join(perfdata, " "))
+	logResult(opts, result.Status, status_line)
+	if opts.Output == "json" {
+		out := newJSONOutput(result, url_str, resp, size, diff, perfdata)
+		out.Assertions = append(responseOptions(opts), bodyOptions(opts)...)
+		printJSON(out)
+		exit(opts, result.Status)
+	}
+	if opts.PerfdataOnly {
+		fmt.Println(strings.Join(perfdata, " "))
+		exit(opts, result.Status)
 	}
-	fmt.Printf("HTTP %s: %s %s - %d bytes in %.3f second response time |time=%.6fs;;;%.6f size=%dB;;;0\n", result_str, resp.Proto, resp.Status, size, diff.Seconds(), diff.Seconds(), 0.0, size)
-	if result_message != "" {
-		fmt.Println(result_message)
+	fmt.Println(status_line)
+	for _, message := range result.Messages {
+		fmt.Println(message)
 	}
 	if len(additional_out) > 0 {
 		fmt.Printf("\n%s", additional_out)
 	}
-	os.Exit(nagios_status)
+	exit(opts, result.Status)
 }
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestStatusListMatches(t *testing.T) {
+	tests := []struct {
+		code  int
+		list  string
+		match bool
+	}{
+		{200, "200", true},
+		{201, "200", false},
+		{301, "200,301,302", true},
+		{204, "200-299", true},
+		{299, "200-299", true},
+		{300, "200-299", false},
+		{404, "2xx,4xx", true},
+		{404, "2XX", false},
+		{503, "5xx", true},
+		{200, " 200 , 404 ", true},
+	}
+	for _, tt := range tests {
+		if got := statusListMatches(tt.code, tt.list); got != tt.match {
+			t.Errorf("statusListMatches(%d, %q) = %v, want %v", tt.code, tt.list, got, tt.match)
+		}
+	}
+}
+
+func TestValidateStatusList(t *testing.T) {
+	tests := []struct {
+		list string
+		ok   bool
+	}{
+		{"200", true},
+		{"200,301,302", true},
+		{"200-299", true},
+		{"2xx", true},
+		{"5XX", true},
+		{"2xx,404,500-503", true},
+		{"abc", false},
+		{"abc,2xxx", false},
+		{"2xxx", false},
+		{"6xx", false},
+		{"20", false},
+		{"2000", false},
+		{"099", false},
+		{"600", false},
+		{"299-200", false},
+		{"200-", false},
+		{"200-2xx", false},
+		{"200,", false},
+	}
+	for _, tt := range tests {
+		err := validateStatusList(tt.list)
+		if (err == nil) != tt.ok {
+			t.Errorf("validateStatusList(%q) = %v, want ok %v", tt.list, err, tt.ok)
+		}
+	}
+}
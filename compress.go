@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// compressible reports whether the content type benefits from compression.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "application/xhtml+xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// compressed reports whether the response was sent with a content coding.
+// The transport removes Content-Encoding when it decompressed the body.
+func compressed(resp *http.Response) bool {
+	return resp.Uncompressed || resp.Header.Get("Content-Encoding") != ""
+}
+
+// checkUncompressed warns about compressible responses larger than
+// threshold which were sent uncompressed.
+func checkUncompressed(resp *http.Response, size, threshold int64, result *Result) {
+	contentType := resp.Header.Get("Content-Type")
+	if size > threshold && compressible(contentType) && !compressed(resp) {
+		result.Add(NagiosWarning, "%d bytes of %s served without compression", size, contentType)
+	}
+}
+
+// checkVaryEncoding warns about compressed responses whose Vary header does
+// not include Accept-Encoding, which lets caches serve the compressed body
+// to clients that did not ask for it.
+func checkVaryEncoding(resp *http.Response, result *Result) {
+	if !compressed(resp) {
+		return
+	}
+	vary := resp.Header.Values("Vary")
+	for _, v := range splitList(vary) {
+		if v == "*" || strings.EqualFold(v, "Accept-Encoding") {
+			return
+		}
+	}
+	if len(vary) == 0 {
+		result.Add(NagiosWarning, "compressed response without Vary: Accept-Encoding")
+		return
+	}
+	result.Add(NagiosWarning, "compressed response with Vary: %s, missing Accept-Encoding", strings.Join(vary, ", "))
+}
+
+// unsupportedCodingError is returned by decodeBody for a content coding it
+// cannot undo, such as br.
+type unsupportedCodingError struct {
+	coding string
+}
+
+func (e unsupportedCodingError) Error() string {
+	return "unsupported content encoding: " + e.coding
+}
+
+// decodeBody undoes the content codings of a body the transport did not
+// decompress itself, e.g. because Accept-Encoding was set explicitly.
+func decodeBody(encoding string, body []byte) ([]byte, error) {
+	codings := strings.Split(encoding, ",")
+	// codings are listed in the order they were applied
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		var err error
+		switch coding := strings.ToLower(strings.TrimSpace(codings[i])); coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(body))
+		case "deflate":
+			// deflate is zlib wrapped, but some servers send it raw
+			r, err = zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				r, err = flate.NewReader(bytes.NewReader(body)), nil
+			}
+		default:
+			return nil, unsupportedCodingError{coding}
+		}
+		if err != nil {
+			return nil, err
+		}
+		body, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
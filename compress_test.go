@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"testing"
+)
+
+const jsonFixture = `{"status":"ok","items":[1,2,3]}`
+
+func encodeFixture(t *testing.T, newWriter func(io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write([]byte(jsonFixture)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	gzipped := encodeFixture(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	zlibbed := encodeFixture(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })
+	raw := encodeFixture(t, func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	})
+
+	tests := []struct {
+		encoding string
+		body     []byte
+	}{
+		{"gzip", gzipped},
+		{"x-gzip", gzipped},
+		{"GZIP", gzipped},
+		{"deflate", zlibbed},
+		{"deflate", raw},
+		{"identity", []byte(jsonFixture)},
+		{"identity, gzip", gzipped},
+	}
+	for _, tt := range tests {
+		got, err := decodeBody(tt.encoding, tt.body)
+		if err != nil {
+			t.Errorf("decodeBody(%q) error: %s", tt.encoding, err)
+			continue
+		}
+		if string(got) != jsonFixture {
+			t.Errorf("decodeBody(%q) = %q, want %q", tt.encoding, got, jsonFixture)
+		}
+	}
+}
+
+func TestDecodeBodyUnsupported(t *testing.T) {
+	_, err := decodeBody("br", []byte{0x0b, 0x02, 0x80})
+	var unsupported unsupportedCodingError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("decodeBody(br) error = %v, want unsupportedCodingError", err)
+	}
+	if unsupported.coding != "br" {
+		t.Errorf("coding = %q, want br", unsupported.coding)
+	}
+}
+
+func TestDecodeBodyCorrupt(t *testing.T) {
+	_, err := decodeBody("gzip", []byte("not gzip"))
+	if err == nil {
+		t.Fatal("decodeBody(gzip) of plain text succeeded")
+	}
+	var unsupported unsupportedCodingError
+	if errors.As(err, &unsupported) {
+		t.Errorf("corrupt gzip reported as unsupported coding")
+	}
+}
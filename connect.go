@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runConnectOnly establishes the TCP connection, and the TLS handshake with
+// -S, without sending a request. The elapsed time is compared with the
+// response time thresholds and the process exits.
+func runConnectOnly(opts Options, dial func(ctx context.Context, network, addr string) (net.Conn, error), conf *tls.Config, serverName string) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	addr := net.JoinHostPort(opts.Ipaddr, strconv.Itoa(opts.Port))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(opts.Timeout)*time.Second)
+	defer cancel()
+
+	t1 := time.Now()
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		if isConnectTimeout(err) {
+			fail(opts, stateByName[opts.TimeoutState], "connection timed out: %s", err)
+		}
+		fail(opts, NagiosCritical, "%s", err)
+	}
+	defer conn.Close()
+	t2 := time.Now()
+	connect := t2.Sub(t1)
+	perfdata := []string{fmt.Sprintf("connect=%.6fs;;;0", connect.Seconds())}
+
+	total := connect
+	if opts.Ssl {
+		c := conf.Clone()
+		if c.ServerName == "" {
+			c.ServerName = hostname(serverName)
+		}
+		tc := tls.Client(conn, c)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			fail(opts, NagiosCritical, "TLS handshake failed: %s", err)
+		}
+		handshake := time.Since(t2)
+		total += handshake
+		perfdata = append(perfdata, fmt.Sprintf("tls=%.6fs;;;0", handshake.Seconds()))
+	}
+
+	result := Result{}
+	if total.Seconds() > opts.Crit {
+		result.Add(NagiosCritical, "connect time %s exceeded critical threshold %s", formatTime(total.Seconds(), opts.Unit), formatTime(opts.Crit, opts.Unit))
+	} else if total.Seconds() > opts.Warn {
+		result.Add(NagiosWarning, "connect time %s exceeded warning threshold %s", formatTime(total.Seconds(), opts.Unit), formatTime(opts.Warn, opts.Unit))
+	}
+	result.Perfdata = perfdata
+
+	line := fmt.Sprintf("HTTP %s: connected to %s in %.3f second |%s", statusString(result.Status), addr, total.Seconds(), strings.Join(perfdata, " "))
+	logResult(opts, result.Status, line)
+	if opts.Output == "json" {
+		printJSON(newJSONOutput(result, addr, nil, 0, total, perfdata))
+		exit(opts, result.Status)
+	}
+	if opts.PerfdataOnly {
+		fmt.Println(strings.Join(perfdata, " "))
+		exit(opts, result.Status)
+	}
+	fmt.Println(line)
+	for _, message := range result.Messages {
+		fmt.Println(message)
+	}
+	exit(opts, result.Status)
+}
+
+// isConnectTimeout reports whether err was caused by a timeout while
+// establishing the connection.
+func isConnectTimeout(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout()
+}
+
+// isTimeout reports whether err was caused by any timeout, including
+// the overall --timeout of the request.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// checkForbiddenCookies reports every cookie set by the response whose name
+// is in names.
+func checkForbiddenCookies(cookies []*http.Cookie, names []string, state int, result *Result) {
+	for _, name := range names {
+		for _, cookie := range cookies {
+			if cookie.Name == name {
+				result.Add(state, "response sets forbidden cookie `%s`", name)
+				break
+			}
+		}
+	}
+}
+
+// parseCookies parses --cookie values of the form name=value.
+func parseCookies(values []string) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid cookie `%s`, expected name=value", v)
+		}
+		cookies = append(cookies, &http.Cookie{Name: strings.TrimSpace(kv[0]), Value: kv[1]})
+	}
+	return cookies, nil
+}
+
+// cookiePattern is a regular expression the value of the named cookie has
+// to match.
+type cookiePattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// parseCookiePatterns parses values of the form name=regex.
+func parseCookiePatterns(values []string) ([]cookiePattern, error) {
+	var patterns []cookiePattern
+	for _, v := range values {
+		kv := strings.SplitN(v, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid cookie pattern `%s`, expected name=regex", v)
+		}
+		re, err := regexp.Compile(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookie pattern `%s`: %s", v, err)
+		}
+		patterns = append(patterns, cookiePattern{name: kv[0], re: re})
+	}
+	return patterns, nil
+}
+
+// checkCookieValues reports cookies which are not set or whose value does
+// not match the pattern. With redact only the length of the value is shown.
+func checkCookieValues(cookies []*http.Cookie, patterns []cookiePattern, redact bool, result *Result) {
+	for _, p := range patterns {
+		var found *http.Cookie
+		for _, cookie := range cookies {
+			if cookie.Name == p.name {
+				found = cookie
+				break
+			}
+		}
+		if found == nil {
+			result.Add(NagiosCritical, "cookie `%s` is not set", p.name)
+			continue
+		}
+		if p.re.MatchString(found.Value) {
+			continue
+		}
+		value := "`" + found.Value + "`"
+		if redact {
+			value = fmt.Sprintf("(%d bytes, redacted)", len(found.Value))
+		}
+		result.Add(NagiosCritical, "cookie `%s` value %s does not match `%s`", p.name, value, p.re)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build go1.25
+
+package main
+
+import "crypto/tls"
+
+// curveSupported is false when built with a Go version whose
+// ConnectionState does not report the key exchange group.
+const curveSupported = true
+
+// curveNames lists the key exchange groups accepted by --expect-curve.
+var curveNames = map[string]tls.CurveID{
+	"X25519":         tls.X25519,
+	"P256":           tls.CurveP256,
+	"P384":           tls.CurveP384,
+	"P521":           tls.CurveP521,
+	"X25519MLKEM768": tls.X25519MLKEM768,
+}
+
+// negotiatedCurve returns the key exchange group of the connection, 0
+// when there was no ephemeral key exchange.
+func negotiatedCurve(state *tls.ConnectionState) tls.CurveID {
+	return state.CurveID
+}
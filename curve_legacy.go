@@ -0,0 +1,16 @@
+//go:build !go1.25
+
+package main
+
+import "crypto/tls"
+
+// curveSupported is false as ConnectionState.CurveID was added in Go 1.25.
+const curveSupported = false
+
+// curveNames is empty, --expect-curve is rejected by parseCurve.
+var curveNames = map[string]tls.CurveID{}
+
+// negotiatedCurve is never called without curveSupported.
+func negotiatedCurve(state *tls.ConnectionState) tls.CurveID {
+	return 0
+}
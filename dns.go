@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// dnsDialContext returns a DialContext function which resolves the host
+// itself, bounding every DNS query by timeout and retrying a failed lookup
+// up to retries times before dialing.
+func dnsDialContext(dialer *net.Dialer, timeout time.Duration, retries int) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: timeout}
+			return d.DialContext(ctx, network, address)
+		},
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		var ips []net.IPAddr
+		for attempt := 0; attempt <= retries; attempt++ {
+			lookupCtx := ctx
+			cancel := func() {}
+			if timeout > 0 {
+				lookupCtx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			ips, err = resolver.LookupIPAddr(lookupCtx, host)
+			cancel()
+			var dnsErr *net.DNSError
+			if err == nil || ctx.Err() != nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+				break
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
+
+// isDNSError reports whether err was caused by a failed DNS lookup.
+func isDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
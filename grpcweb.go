@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// grpcCodeNames are the names of the gRPC status codes.
+var grpcCodeNames = []string{
+	"OK",
+	"CANCELLED",
+	"UNKNOWN",
+	"INVALID_ARGUMENT",
+	"DEADLINE_EXCEEDED",
+	"NOT_FOUND",
+	"ALREADY_EXISTS",
+	"PERMISSION_DENIED",
+	"RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION",
+	"ABORTED",
+	"OUT_OF_RANGE",
+	"UNIMPLEMENTED",
+	"INTERNAL",
+	"UNAVAILABLE",
+	"DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+func grpcCodeName(code int) string {
+	if code >= 0 && code < len(grpcCodeNames) {
+		return grpcCodeNames[code]
+	}
+	return "CODE(" + strconv.Itoa(code) + ")"
+}
+
+// grpcWebFrame wraps a serialized message in a gRPC-Web data frame.
+func grpcWebFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+// grpcWebTrailer returns the trailer frame of a gRPC-Web response body.
+func grpcWebTrailer(body []byte) http.Header {
+	for len(body) >= 5 {
+		flag := body[0]
+		n := int(binary.BigEndian.Uint32(body[1:5]))
+		if 5+n > len(body) {
+			break
+		}
+		if flag&0x80 != 0 {
+			// the trailer block has no terminating empty line
+			block := append(append([]byte{}, body[5:5+n]...), "\r\n\r\n"...)
+			r := textproto.NewReader(bufio.NewReader(bytes.NewReader(block)))
+			trailer, _ := r.ReadMIMEHeader()
+			return http.Header(trailer)
+		}
+		body = body[5+n:]
+	}
+	return nil
+}
+
+// checkGrpcWeb reports the gRPC status of the response. A trailers-only
+// response carries it in the headers, otherwise it is in the trailer frame.
+func checkGrpcWeb(header http.Header, body []byte, result *Result) {
+	status := header.Get("Grpc-Status")
+	message := header.Get("Grpc-Message")
+	if status == "" {
+		if trailer := grpcWebTrailer(body); trailer != nil {
+			status = trailer.Get("Grpc-Status")
+			message = trailer.Get("Grpc-Message")
+		}
+	}
+	if status == "" {
+		result.Add(NagiosCritical, "grpc-status not found in response")
+		return
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(status))
+	if err != nil {
+		result.Add(NagiosUnknown, "invalid grpc-status: %s", status)
+		return
+	}
+	if code != 0 {
+		result.Add(NagiosCritical, "grpc-status %s: %s", grpcCodeName(code), message)
+	}
+}
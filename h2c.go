@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math"
+	"net"
+
+	"golang.org/x/net/http2"
+)
+
+// h2cTransport returns a transport speaking HTTP/2 over cleartext with
+// prior knowledge, connecting with dial. A maxHeaderBytes above zero limits
+// the response header list as --max-header-bytes does for HTTP/1.
+func h2cTransport(dial func(ctx context.Context, network, addr string) (net.Conn, error), maxHeaderBytes int64) *http2.Transport {
+	t := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+	}
+	if maxHeaderBytes > 0 {
+		t.MaxHeaderListSize = uint32(maxHeaderBytes)
+		if maxHeaderBytes > math.MaxUint32 {
+			t.MaxHeaderListSize = math.MaxUint32
+		}
+	}
+	return t
+}
+
+// isH2CUnsupported reports whether err means the server rejected the
+// HTTP/2 preface. An HTTP/1 server answers it with an error response,
+// whose status line reads as a frame header of an impossible length.
+// Resets, EOF and the like say nothing about h2c and are left to the
+// caller.
+func isH2CUnsupported(err error) bool {
+	return errors.Is(err, http2.ErrFrameTooLarge)
+}
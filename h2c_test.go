@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestIsH2CUnsupported(t *testing.T) {
+	var dialer net.Dialer
+	client := &http.Client{Transport: h2cTransport(dialer.DialContext, 0)}
+
+	// an HTTP/1 only server answers the preface with 400 Bad Request
+	http1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer http1.Close()
+	_, err := client.Get(http1.URL)
+	if err == nil || !isH2CUnsupported(err) {
+		t.Errorf("HTTP/1 server: isH2CUnsupported(%v) = false, want true", err)
+	}
+
+	// a server closing the connection has not rejected the preface
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, err = client.Get("http://" + ln.Addr().String() + "/")
+	if err == nil || isH2CUnsupported(err) {
+		t.Errorf("closed connection: isH2CUnsupported(%v) = true, want false", err)
+	}
+
+	if isH2CUnsupported(context.DeadlineExceeded) {
+		t.Error("isH2CUnsupported(context.DeadlineExceeded) = true, want false")
+	}
+}
+
+func TestH2CMaxHeaderBytes(t *testing.T) {
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/many" {
+			for i := 0; i < 40; i++ {
+				w.Header().Set(fmt.Sprintf("X-Field-%d", i), strings.Repeat("x", 100))
+			}
+		} else {
+			w.Header().Set("X-Large", strings.Repeat("x", 2048))
+		}
+	}), &http2.Server{}))
+	defer srv.Close()
+
+	var dialer net.Dialer
+	for _, tt := range []struct {
+		path     string
+		max      int64
+		tooLarge bool
+	}{
+		{"/", 0, false},
+		{"/", 8192, false},
+		{"/", 1024, true},
+		{"/many", 8192, false},
+		{"/many", 1024, true},
+	} {
+		client := &http.Client{Transport: h2cTransport(dialer.DialContext, tt.max)}
+		resp, err := client.Get(srv.URL + tt.path)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if tt.tooLarge && (err == nil || !isHeaderTooLarge(err)) {
+			t.Errorf("%s with max %d: error %v, want the header rejected as too large", tt.path, tt.max, err)
+		}
+		if !tt.tooLarge && err != nil {
+			t.Errorf("%s with max %d: error %v", tt.path, tt.max, err)
+		}
+	}
+}
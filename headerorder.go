@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"sync"
+)
+
+// maxRecordedHeader bounds the raw response header kept for order checks.
+const maxRecordedHeader = 64 * 1024
+
+// headerRecorder keeps the raw bytes of the first response header block
+// read from the connection, since net/http does not preserve header order.
+type headerRecorder struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	done bool
+}
+
+func (r *headerRecorder) record(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return
+	}
+	r.buf.Write(p)
+	if i := bytes.Index(r.buf.Bytes(), []byte("\r\n\r\n")); i >= 0 {
+		r.buf.Truncate(i)
+		r.done = true
+	} else if r.buf.Len() > maxRecordedHeader {
+		r.done = true
+	}
+}
+
+// names returns the header field names in the order they were received.
+func (r *headerRecorder) names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	lines := strings.Split(r.buf.String(), "\r\n")
+	for _, line := range lines[1:] {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			names = append(names, strings.TrimSpace(line[:i]))
+		}
+	}
+	return names
+}
+
+type recordingConn struct {
+	net.Conn
+	rec *headerRecorder
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.rec.record(p[:n])
+	return n, err
+}
+
+// recordingTLSConn records above TLS and still exposes the TLS state,
+// which net/http looks for to fill in resp.TLS.
+type recordingTLSConn struct {
+	recordingConn
+	tls *tls.Conn
+}
+
+func (c *recordingTLSConn) ConnectionState() tls.ConnectionState {
+	return c.tls.ConnectionState()
+}
+
+func (c *recordingTLSConn) HandshakeContext(ctx context.Context) error {
+	return c.tls.HandshakeContext(ctx)
+}
+
+// recordingDialers wrap dial so that the plain text of the response is
+// recorded. TLS is set up here rather than by the transport, which still
+// runs the handshake, and only HTTP/1.1 is offered since HTTP/2 headers
+// are compressed.
+func recordingDialers(dial func(ctx context.Context, network, addr string) (net.Conn, error), conf *tls.Config, rec *headerRecorder) (
+	func(ctx context.Context, network, addr string) (net.Conn, error),
+	func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	plain := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &recordingConn{Conn: conn, rec: rec}, nil
+	}
+	secure := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c := conf.Clone()
+		c.NextProtos = []string{"http/1.1"}
+		if c.ServerName == "" {
+			c.ServerName = hostname(addr)
+		}
+		tc := tls.Client(conn, c)
+		return &recordingTLSConn{recordingConn: recordingConn{Conn: tc, rec: rec}, tls: tc}, nil
+	}
+	return plain, secure
+}
+
+// checkHeaderOrder requires the named headers to appear in the given
+// relative order. Other headers may appear in between.
+func checkHeaderOrder(received, expected []string, result *Result) {
+	position := map[string]int{}
+	for i, name := range received {
+		key := strings.ToLower(name)
+		if _, ok := position[key]; !ok {
+			position[key] = i
+		}
+	}
+	last := -1
+	for _, name := range expected {
+		i, ok := position[strings.ToLower(name)]
+		if !ok {
+			result.Add(NagiosCritical, "header %s not found for order check", name)
+			return
+		}
+		if i < last {
+			result.Add(NagiosCritical, "header order is not %s (got %s)", strings.Join(expected, ","), strings.Join(received, ","))
+			return
+		}
+		last = i
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRecordingDialersTLS runs what -S --expect-header-order -C does: the
+// header order is recorded while resp.TLS stays available to the
+// certificate checks.
+func TestRecordingDialersTLS(t *testing.T) {
+	pair, cert := selfSignedCert(t, time.Now().Add(10*24*time.Hour))
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-First", "1")
+		w.Header().Set("Content-Type", "text/plain")
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	tr := &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	rec := &headerRecorder{}
+	tr.DialContext, tr.DialTLSContext = recordingDialers(nil, tr.TLSClientConfig, rec)
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		t.Fatal("resp.TLS is not set through the recording dialer")
+	}
+	var result Result
+	checkCertExpiry(resp.TLS.PeerCertificates[0], 30, 14, &result)
+	if result.Status != NagiosCritical || len(result.Perfdata) != 1 || !strings.HasPrefix(result.Perfdata[0], "cert_days=9;") {
+		t.Errorf("checkCertExpiry = %s %v %v, want CRITICAL with cert_days=9", statusString(result.Status), result.Messages, result.Perfdata)
+	}
+
+	names := rec.names()
+	var got []string
+	for _, name := range names {
+		if name == "X-First" || name == "Content-Type" {
+			got = append(got, name)
+		}
+	}
+	if want := []string{"Content-Type", "X-First"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recorded headers %v, want %v in that order", names, want)
+	}
+}
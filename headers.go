@@ -0,0 +1,427 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// readHeaderFile reads request headers from path, one "Name: Value" per
+// line. Lines starting with whitespace continue the previous value, and
+// blank lines and lines starting with '#' are ignored.
+func readHeaderFile(path string) (http.Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header := http.Header{}
+	var name, value string
+	flush := func() {
+		if name != "" {
+			header.Add(name, value)
+		}
+		name, value = "", ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			flush()
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if name == "" {
+				return nil, fmt.Errorf("%s:%d: continuation line without header", path, lineno)
+			}
+			value += " " + trimmed
+			continue
+		}
+		flush()
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("%s:%d: invalid header line", path, lineno)
+		}
+		name = strings.TrimSpace(kv[0])
+		value = strings.TrimSpace(kv[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return header, nil
+}
+
+// headerBytes approximates the size of the response header as sent on the
+// wire in HTTP/1.1, including the status line.
+func headerBytes(resp *http.Response) int {
+	n := len(resp.Proto) + 1 + len(resp.Status) + 2
+	for name, values := range resp.Header {
+		for _, value := range values {
+			n += len(name) + 2 + len(value) + 2
+		}
+	}
+	return n + 2
+}
+
+// checkHeaderBytes adds the approximate header size as perfdata. Over
+// HTTP/1 a header larger than max has already been rejected by the
+// transport; the HTTP/2 transport allows some slack over the limit, so the
+// size is checked here.
+func checkHeaderBytes(resp *http.Response, max int64, result *Result) {
+	size := headerBytes(resp)
+	if resp.ProtoMajor == 2 && int64(size) > max {
+		result.Add(NagiosCritical, "response header of about %d bytes larger than %d bytes", size, max)
+	}
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("header_bytes=%dB;;%d;0", size, max))
+}
+
+// isHeaderTooLarge reports whether err is the transport rejecting a
+// response header larger than MaxResponseHeaderBytes. Over HTTP/2 the limit
+// is advertised as the header list size, and depending on where it is
+// crossed the frame reader drops the connection with a protocol or
+// compression error rather than naming it, so those count too.
+func isHeaderTooLarge(err error) bool {
+	var connErr http2.ConnectionError
+	if errors.As(err, &connErr) {
+		return http2.ErrCode(connErr) == http2.ErrCodeProtocol || http2.ErrCode(connErr) == http2.ErrCodeCompression
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "server response headers exceeded") ||
+		strings.Contains(msg, "response header list larger than advertised limit")
+}
+
+// headerThreshold is a set of rules applied to a numeric header value.
+type headerThreshold struct {
+	name  string
+	rules []thresholdRule
+}
+
+// thresholdRule raises state when "value op limit" holds.
+type thresholdRule struct {
+	op    string
+	limit float64
+	state int
+}
+
+// parseHeaderThreshold parses "Name:<100:crit,<500:warn".
+func parseHeaderThreshold(s string) (headerThreshold, error) {
+	kv := strings.SplitN(s, ":", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+		return headerThreshold{}, fmt.Errorf("invalid header threshold `%s`, expected Name:<op><value>:<state>,...", s)
+	}
+	t := headerThreshold{name: strings.TrimSpace(kv[0])}
+	for _, r := range strings.Split(kv[1], ",") {
+		i := strings.LastIndex(r, ":")
+		if i < 0 {
+			return headerThreshold{}, fmt.Errorf("invalid header threshold rule `%s`, expected <op><value>:<state>", r)
+		}
+		op, limit, err := parseComparison(r[:i])
+		if err != nil {
+			return headerThreshold{}, fmt.Errorf("invalid header threshold rule `%s`: %s", r, err)
+		}
+		var state int
+		switch strings.ToLower(strings.TrimSpace(r[i+1:])) {
+		case "warn", "warning":
+			state = NagiosWarning
+		case "crit", "critical":
+			state = NagiosCritical
+		default:
+			return headerThreshold{}, fmt.Errorf("invalid state in header threshold rule `%s`", r)
+		}
+		t.rules = append(t.rules, thresholdRule{op: op, limit: limit, state: state})
+	}
+	return t, nil
+}
+
+// perfdataLabel turns a header name into a perfdata label.
+func perfdataLabel(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+}
+
+// checkHeaderThreshold applies the rules of t to the numeric value of the
+// header and adds the value as perfdata.
+func checkHeaderThreshold(header http.Header, t headerThreshold, result *Result) {
+	raw := header.Get(t.name)
+	if raw == "" {
+		result.Add(NagiosUnknown, "no %s header in response", t.name)
+		return
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		result.Add(NagiosUnknown, "%s header is not numeric: %s", t.name, raw)
+		return
+	}
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("%s=%g;;;", perfdataLabel(t.name), value))
+	// only the most severe matching rule is reported
+	var worst *thresholdRule
+	for i, r := range t.rules {
+		if compareFloat(r.op, value, r.limit) && (worst == nil || severity[r.state] > severity[worst.state]) {
+			worst = &t.rules[i]
+		}
+	}
+	if worst != nil {
+		result.Add(worst.state, "%s is %g (%s %g)", t.name, value, worst.op, worst.limit)
+	}
+}
+
+// checkClockSkew compares the Date header with the local clock at the
+// middle of the request.
+func checkClockSkew(header http.Header, sent, received time.Time, max float64, result *Result) {
+	value := header.Get("Date")
+	if value == "" {
+		result.Add(NagiosUnknown, "no Date header in response")
+		return
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		result.Add(NagiosUnknown, "invalid Date header: %s", value)
+		return
+	}
+	local := sent.Add(received.Sub(sent) / 2)
+	skew := date.Sub(local).Seconds()
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("clock_skew=%.3fs;%.3f:%.3f", skew, -max, max))
+	if math.Abs(skew) > max {
+		result.Add(NagiosWarning, "server clock skew %.3fs exceeded threshold %.3fs", skew, max)
+	}
+}
+
+// checkCacheAge warns when the Age header shows cached content older than
+// max. A missing header counts as a cache miss unless missingUnknown.
+func checkCacheAge(header http.Header, max int64, missingUnknown bool, result *Result) {
+	value := header.Get("Age")
+	var age int64
+	if value == "" {
+		if missingUnknown {
+			result.Add(NagiosUnknown, "no Age header in response")
+			return
+		}
+	} else {
+		var err error
+		age, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || age < 0 {
+			result.Add(NagiosUnknown, "invalid Age header: %s", value)
+			return
+		}
+	}
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("cache_age=%ds;%d;;0", age, max))
+	if age > max {
+		result.Add(NagiosWarning, "cached content is %d seconds old, exceeded %d", age, max)
+	}
+}
+
+// parseHTTPTime accepts an HTTP-date or an RFC 3339 time.
+func parseHTTPTime(s string) (time.Time, error) {
+	if t, err := http.ParseTime(s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", s)
+	}
+	return t, nil
+}
+
+// conditionalNote describes which validator a 304 response matched, as far
+// as the response headers tell.
+func conditionalNote(header http.Header, etag string, since time.Time) string {
+	var honored []string
+	if etag != "" && header.Get("ETag") != "" && header.Get("ETag") == etag {
+		honored = append(honored, "If-None-Match")
+	}
+	if !since.IsZero() {
+		if lm, err := http.ParseTime(header.Get("Last-Modified")); err == nil && !lm.After(since) {
+			honored = append(honored, "If-Modified-Since")
+		}
+	}
+	if len(honored) == 0 {
+		return "not modified"
+	}
+	return "not modified, matched " + strings.Join(honored, " and ")
+}
+
+var realmRe = regexp.MustCompile(`(?i)\brealm=(?:"((?:[^"\\]|\\.)*)"|([^\s,]+))`)
+
+// checkAuthRealm requires a 401 response whose challenge advertises realm.
+func checkAuthRealm(resp *http.Response, realm string, result *Result) {
+	if resp.StatusCode != http.StatusUnauthorized {
+		result.Add(NagiosCritical, "expected a 401 authentication challenge, got %d", resp.StatusCode)
+		return
+	}
+	challenges := resp.Header.Values("WWW-Authenticate")
+	if len(challenges) == 0 {
+		result.Add(NagiosCritical, "401 response has no WWW-Authenticate challenge")
+		return
+	}
+	var realms []string
+	for _, challenge := range challenges {
+		for _, m := range realmRe.FindAllStringSubmatch(challenge, -1) {
+			r := strings.ReplaceAll(m[1], `\`, "")
+			if m[2] != "" {
+				r = m[2]
+			}
+			if r == realm {
+				return
+			}
+			realms = append(realms, r)
+		}
+	}
+	result.Add(NagiosCritical, "authentication realm is not `%s` (got `%s`)", realm, strings.Join(realms, "`, `"))
+}
+
+// checkRequiredHeaders reports the names which are not present in header.
+func checkRequiredHeaders(header http.Header, names []string, state int, result *Result) {
+	var missing []string
+	for _, name := range names {
+		if len(header.Values(name)) == 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		result.Add(state, "missing required headers: %s", strings.Join(missing, ", "))
+	}
+}
+
+// checkExpectedHeader checks an --expect-header of the form Name:Value, or
+// Name alone which only requires the header to be present.
+func checkExpectedHeader(header http.Header, spec string, result *Result) {
+	name, want, hasValue := strings.Cut(spec, ":")
+	name = strings.TrimSpace(name)
+	if len(header.Values(name)) == 0 {
+		result.Add(NagiosCritical, "header %s is missing", name)
+		return
+	}
+	if !hasValue {
+		return
+	}
+	want = strings.TrimSpace(want)
+	if got := header.Get(name); got != want {
+		result.Add(NagiosCritical, "header %s is `%s`, expected `%s`", name, got, want)
+	}
+}
+
+// checkAllow compares the methods advertised by the Allow header, as sent
+// with a 405 or an OPTIONS response, with the expected set.
+func checkAllow(header http.Header, expected []string, result *Result) {
+	values := header.Values("Allow")
+	if len(values) == 0 {
+		result.Add(NagiosCritical, "no Allow header in response")
+		return
+	}
+	allowed := map[string]bool{}
+	for _, method := range splitList(values) {
+		allowed[strings.ToUpper(method)] = true
+	}
+	var missing, extra []string
+	want := map[string]bool{}
+	for _, method := range expected {
+		method = strings.ToUpper(method)
+		want[method] = true
+		if !allowed[method] {
+			missing = append(missing, method)
+		}
+	}
+	for _, method := range splitList(values) {
+		if method = strings.ToUpper(method); !want[method] {
+			extra = append(extra, method)
+		}
+	}
+	if len(missing) > 0 {
+		result.Add(NagiosCritical, "Allow header is missing %s", strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		result.Add(NagiosCritical, "Allow header has unexpected %s", strings.Join(extra, ", "))
+	}
+}
+
+// checkAllowsMethod requires the Allow header of an OPTIONS response to
+// list method.
+func checkAllowsMethod(header http.Header, method string, result *Result) {
+	values := header.Values("Allow")
+	if len(values) == 0 {
+		result.Add(NagiosCritical, "no Allow header in OPTIONS response")
+		return
+	}
+	for _, m := range splitList(values) {
+		if strings.EqualFold(m, method) {
+			return
+		}
+	}
+	result.Add(NagiosCritical, "method %s is not allowed (Allow: %s)", strings.ToUpper(method), strings.Join(values, ", "))
+}
+
+// altSvc is one alternative service advertised by an Alt-Svc header.
+type altSvc struct {
+	Protocol  string
+	Authority string
+	Params    []string
+}
+
+func (a altSvc) String() string {
+	s := a.Protocol + "=\"" + a.Authority + "\""
+	for _, p := range a.Params {
+		s += "; " + p
+	}
+	return s
+}
+
+// parseAltSvc parses the Alt-Svc header values. "clear" yields no entries.
+func parseAltSvc(values []string) []altSvc {
+	var services []altSvc
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			parts := strings.Split(entry, ";")
+			kv := strings.SplitN(strings.TrimSpace(parts[0]), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			svc := altSvc{Protocol: kv[0], Authority: strings.Trim(kv[1], `"`)}
+			for _, p := range parts[1:] {
+				if p = strings.TrimSpace(p); p != "" {
+					svc.Params = append(svc.Params, p)
+				}
+			}
+			services = append(services, svc)
+		}
+	}
+	return services
+}
+
+// checkAltSvc requires an Alt-Svc entry with the protocol and authority of
+// expected, e.g. h3=":443". Parameters such as ma are not compared.
+func checkAltSvc(services []altSvc, expected string, result *Result) {
+	want := parseAltSvc([]string{expected})
+	if len(want) != 1 {
+		result.Add(NagiosUnknown, "invalid --expect-altsvc `%s`, expected protocol=\"[host]:port\"", expected)
+		return
+	}
+	for _, svc := range services {
+		if svc.Protocol == want[0].Protocol && svc.Authority == want[0].Authority {
+			return
+		}
+	}
+	if len(services) == 0 {
+		result.Add(NagiosCritical, "no Alt-Svc advertised, expected %s", want[0])
+		return
+	}
+	var got []string
+	for _, svc := range services {
+		got = append(got, svc.String())
+	}
+	result.Add(NagiosCritical, "Alt-Svc does not advertise %s (got %s)", want[0], strings.Join(got, ", "))
+}
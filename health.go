@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// actuatorHealth is the shape of a Spring Boot Actuator style health
+// document.
+type actuatorHealth struct {
+	Status     string                    `json:"status"`
+	Components map[string]actuatorHealth `json:"components"`
+}
+
+var actuatorStatus = map[string]int{
+	"UP":             NagiosOk,
+	"OUT_OF_SERVICE": NagiosWarning,
+	"DOWN":           NagiosCritical,
+	"UNKNOWN":        NagiosUnknown,
+}
+
+// checkHealth evaluates body as an actuator health document. The overall
+// status drives the state and unhealthy components go to the long output.
+func checkHealth(body []byte, result *Result) {
+	var h actuatorHealth
+	if err := json.Unmarshal(body, &h); err != nil {
+		result.Add(NagiosUnknown, "health: %s", err)
+		return
+	}
+	status, ok := actuatorStatus[strings.ToUpper(h.Status)]
+	if !ok {
+		result.Add(NagiosUnknown, "health: unknown overall status `%s`", h.Status)
+		return
+	}
+	if status != NagiosOk {
+		result.Add(status, "health: overall status is %s", h.Status)
+	}
+	result.Messages = append(result.Messages, unhealthyComponents("", h)...)
+}
+
+// unhealthyComponents lists every nested component which is not UP,
+// using dotted paths for nested ones.
+func unhealthyComponents(prefix string, h actuatorHealth) []string {
+	components := h.Components
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var lines []string
+	for _, name := range names {
+		c := components[name]
+		path := prefix + name
+		if c.Status != "" && strings.ToUpper(c.Status) != "UP" {
+			lines = append(lines, "health: component "+path+" is "+c.Status)
+		}
+		lines = append(lines, unhealthyComponents(path+".", c)...)
+	}
+	return lines
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var hmacHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hmacStringToSign expands the string-to-sign template with the request
+// method, path (including the query), unix timestamp and body.
+func hmacStringToSign(tmpl string, req *http.Request, body []byte, timestamp string) string {
+	return strings.NewReplacer(
+		`\n`, "\n",
+		"{{method}}", req.Method,
+		"{{path}}", req.URL.RequestURI(),
+		"{{timestamp}}", timestamp,
+		"{{body}}", string(body),
+	).Replace(tmpl)
+}
+
+// signRequest sets header to the hex encoded HMAC of the string-to-sign,
+// and tsHeader, when given, to the timestamp used in it.
+func signRequest(req *http.Request, body, secret []byte, algo, header, tsHeader, tmpl string, now time.Time) error {
+	newHash, ok := hmacHashes[algo]
+	if !ok {
+		return fmt.Errorf("unsupported hmac algorithm: %s", algo)
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(newHash, secret)
+	mac.Write([]byte(hmacStringToSign(tmpl, req, body, timestamp)))
+	req.Header.Set(header, hex.EncodeToString(mac.Sum(nil)))
+	if tsHeader != "" {
+		req.Header.Set(tsHeader, timestamp)
+	}
+	return nil
+}
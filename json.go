@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/icza/dyno"
+)
+
+// jsonPath converts a dotted key such as "xxx.status" into the path
+// arguments of dyno.Get.
+func jsonPath(key string) []interface{} {
+	// https://stackoverflow.com/questions/27689058/convert-string-to-interface
+	t := strings.Split(key, ".")
+	s := make([]interface{}, len(t))
+	for i, v := range t {
+		s[i] = v
+	}
+	return s
+}
+
+// nestedJSON parses v, the string value of key, as JSON and returns the
+// value at subkey within it.
+func nestedJSON(v interface{}, key, subkey string) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("`%s` is not a string containing JSON", key)
+	}
+	var d map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &d); err != nil {
+		return nil, fmt.Errorf("`%s` does not contain valid JSON: %s", key, err)
+	}
+	nested, _ := dyno.Get(d, jsonPath(subkey)...)
+	return nested, nil
+}
+
+// checkJsonPath compares the value at the dotted key of d with want. With
+// op the comparison is numeric, and with subkey the value is a string of
+// nested JSON whose subkey is compared.
+func checkJsonPath(d map[string]interface{}, key, want, op, subkey string, result *Result) {
+	// https://qiita.com/hnakamur/items/c3560a4b780487ef6065
+	v, _ := dyno.Get(d, jsonPath(key)...)
+	if subkey != "" {
+		var err error
+		v, err = nestedJSON(v, key, subkey)
+		if err != nil {
+			result.Add(NagiosUnknown, "%s", err)
+			return
+		}
+		key += " -> " + subkey
+	}
+	if op == "" {
+		if actual, ok := jsonScalar(v); !ok || actual != want {
+			result.Add(NagiosCritical, "`%s` is not `%s`", key, want)
+		}
+		return
+	}
+	expected, _ := strconv.ParseFloat(want, 64)
+	actual, ok := jsonNumber(v)
+	if !ok {
+		result.Add(NagiosUnknown, "`%s` is not numeric: %v", key, v)
+	} else if !compareFloat(op, actual, expected) {
+		result.Add(NagiosCritical, "`%s` is %v, expected %s %v", key, actual, op, expected)
+	}
+}
+
+// jsonScalar formats a JSON string, number or boolean as it is
+// written on the command line, so that true matches `true` and 1.50 `1.5`.
+func jsonScalar(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(s), true
+	}
+	return "", false
+}
+
+// jsonNumber coerces a JSON number, or a string holding one, to float64.
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// parseStatusMap parses "value=state,..." where state is a Nagios status
+// number or name.
+func parseStatusMap(s string) (map[string]int, error) {
+	m := map[string]int{}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid status map entry: %s", pair)
+		}
+		state := strings.ToLower(strings.TrimSpace(kv[1]))
+		status, ok := stateByName[state]
+		if !ok {
+			n, err := strconv.Atoi(state)
+			if err != nil || n < NagiosOk || n > NagiosUnknown {
+				return nil, fmt.Errorf("invalid status map entry: %s", pair)
+			}
+			status = n
+		}
+		m[strings.TrimSpace(kv[0])] = status
+	}
+	return m, nil
+}
+
+// checkStatusFromJSON maps the value at key to a Nagios state.
+func checkStatusFromJSON(body []byte, key string, statusMap map[string]int, result *Result) {
+	var d map[string]interface{}
+	if err := json.Unmarshal(body, &d); err != nil {
+		result.Add(NagiosUnknown, "status from json: %s", err)
+		return
+	}
+	v, err := dyno.Get(d, jsonPath(key)...)
+	if err != nil {
+		result.Add(NagiosUnknown, "status from json: `%s` not found", key)
+		return
+	}
+	value := fmt.Sprint(v)
+	status, ok := statusMap[value]
+	if !ok {
+		result.Add(NagiosUnknown, "status from json: unknown value `%s` of `%s`", value, key)
+		return
+	}
+	if status != NagiosOk {
+		result.Add(status, "`%s` is `%s`", key, value)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCheckJsonPath(t *testing.T) {
+	body := `{"status":"ok","enabled":true,"disabled":false,"count":3,"ratio":1.50,
+		"big":12345678901,"items":[1],"nested":{"ready":true},"nil":null,
+		"payload":"{\"healthy\":true,\"n\":2}"}`
+	var d map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &d); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key, want, op, subkey string
+		status                int
+	}{
+		{"status", "ok", "", "", NagiosOk},
+		{"status", "ng", "", "", NagiosCritical},
+		{"enabled", "true", "", "", NagiosOk},
+		{"enabled", "false", "", "", NagiosCritical},
+		{"disabled", "false", "", "", NagiosOk},
+		{"count", "3", "", "", NagiosOk},
+		{"count", "3.0", "", "", NagiosCritical},
+		{"ratio", "1.5", "", "", NagiosOk},
+		{"big", "12345678901", "", "", NagiosOk},
+		{"nested.ready", "true", "", "", NagiosOk},
+		{"items", "[1]", "", "", NagiosCritical},
+		{"nil", "null", "", "", NagiosCritical},
+		{"missing", "ok", "", "", NagiosCritical},
+		{"count", "2", "gt", "", NagiosOk},
+		{"count", "3", "lt", "", NagiosCritical},
+		{"status", "1", "gt", "", NagiosUnknown},
+		{"payload", "true", "", "healthy", NagiosOk},
+		{"payload", "2", "", "n", NagiosOk},
+		{"status", "ok", "", "healthy", NagiosUnknown},
+	}
+	for _, tt := range tests {
+		var result Result
+		checkJsonPath(d, tt.key, tt.want, tt.op, tt.subkey, &result)
+		if result.Status != tt.status {
+			t.Errorf("checkJsonPath(%q, %q, %q, %q) = %s %v, want %s",
+				tt.key, tt.want, tt.op, tt.subkey, statusString(result.Status), result.Messages, statusString(tt.status))
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// isNoResponse reports whether the connection was closed or reset before
+// any HTTP response arrived.
+func isNoResponse(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || isConnReset(err)
+}
+
+// failNoResponse reports a request which got no HTTP response with the
+// status code 000, as curl does, and exits.
+func failNoResponse(opts Options, url string, err error, elapsed time.Duration) {
+	result := Result{}
+	result.Add(NagiosCritical, "no HTTP response: %s", err)
+	perfdata := []string{
+		timePerfdata(elapsed.Seconds(), opts, false),
+		"http_code=000",
+	}
+	line := fmt.Sprintf("HTTP CRITICAL: 000 No Response - %s |%s %s", err, perfdata[0], perfdata[1])
+	logResult(opts, result.Status, line)
+	if opts.Output == "json" {
+		out := newJSONOutput(result, url, nil, 0, elapsed, perfdata)
+		out.NoResponse = true
+		printJSON(out)
+		exit(opts, result.Status)
+	}
+	if opts.PerfdataOnly {
+		fmt.Println(perfdata[0], perfdata[1])
+		exit(opts, result.Status)
+	}
+	fmt.Println(line)
+	exit(opts, result.Status)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// schemaVersion identifies the layout of the JSON output. Fields are only
+// ever added within a version; renaming or removing one bumps it.
+const schemaVersion = 1
+
+// JSONOutput is the document printed by --output=json.
+type JSONOutput struct {
+	SchemaVersion int         `json:"schema_version"`
+	Status        string      `json:"status"`
+	ExitCode      int         `json:"exit_code"`
+	URL           string      `json:"url,omitempty"`
+	HTTPCode      int         `json:"http_code"`
+	NoResponse    bool        `json:"no_response,omitempty"`
+	Retries       int         `json:"retries,omitempty"`
+	Proto         string      `json:"proto,omitempty"`
+	Bytes         int64       `json:"bytes"`
+	ResponseTime  float64     `json:"response_time"`
+	Message       string      `json:"message"`
+	Messages      []string    `json:"messages"`
+	Timings       JSONTimings `json:"timings"`
+	TLS           *JSONTLS    `json:"tls,omitempty"`
+	Assertions    []string    `json:"assertions,omitempty"`
+	Checks        []Check     `json:"checks"`
+	Perfdata      []string    `json:"perfdata"`
+}
+
+// JSONTimings holds durations in seconds.
+type JSONTimings struct {
+	Total float64 `json:"total"`
+}
+
+// JSONTLS describes the negotiated TLS connection.
+type JSONTLS struct {
+	Version      string     `json:"version"`
+	CipherSuite  string     `json:"cipher_suite"`
+	Certificates []JSONCert `json:"certificates"`
+}
+
+// JSONCert describes a certificate presented by the server.
+type JSONCert struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+func newJSONTLS(state *tls.ConnectionState) *JSONTLS {
+	if state == nil {
+		return nil
+	}
+	t := &JSONTLS{
+		Version:      tls.VersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		Certificates: []JSONCert{},
+	}
+	for _, cert := range state.PeerCertificates {
+		t.Certificates = append(t.Certificates, JSONCert{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			NotAfter: cert.NotAfter,
+		})
+	}
+	return t
+}
+
+// newJSONOutput builds the JSON document for a completed check. resp is
+// nil when no response was received.
+func newJSONOutput(result Result, url string, resp *http.Response, size int64, elapsed time.Duration, perfdata []string) JSONOutput {
+	out := JSONOutput{
+		SchemaVersion: schemaVersion,
+		Status:        statusString(result.Status),
+		ExitCode:      result.Status,
+		URL:           url,
+		Bytes:         size,
+		ResponseTime:  elapsed.Seconds(),
+		Messages:      []string{},
+		Timings:       JSONTimings{Total: elapsed.Seconds()},
+		Checks:        []Check{},
+		Perfdata:      []string{},
+		Retries:       retryCount(),
+	}
+	if resp != nil {
+		out.HTTPCode = resp.StatusCode
+		out.Proto = resp.Proto
+		out.TLS = newJSONTLS(resp.TLS)
+	}
+	if len(result.Messages) > 0 {
+		out.Message = result.Messages[0]
+		out.Messages = result.Messages
+	}
+	if len(result.Checks) > 0 {
+		out.Checks = result.Checks
+	}
+	if len(perfdata) > 0 {
+		out.Perfdata = perfdata
+	}
+	return out
+}
+
+func printJSON(out JSONOutput) {
+	b, err := json.Marshal(out)
+	if err != nil {
+		fmt.Printf("HTTP UNKNOWN - %s\n", err)
+		return
+	}
+	fmt.Println(string(b))
+}
+
+// responseOptions returns the options in use which assert on the response
+// apart from its body.
+func responseOptions(opts Options) []string {
+	var used []string
+	add := func(set bool, name string) {
+		if set {
+			used = append(used, name)
+		}
+	}
+	add(opts.NoRedirect, "--no-redirect-expected")
+	add(opts.ExpectRedirect != "", "--expect-redirect")
+	add(opts.RedirectBody >= 0, "--redirect-max-body")
+	add(opts.AssertInter != "", "--assert-intermediate")
+	add(opts.ExpectSize > 0, "--expect-size")
+	add(opts.MinSize > 0, "--min-size")
+	add(opts.MaxSize > 0, "--max-size")
+	add(len(opts.ForbidCookie) > 0, "--forbid-setcookie")
+	add(len(opts.CookieValue) > 0, "--expect-cookie-value")
+	add(opts.ProbeMethod != "", "--probe-method")
+	add(opts.MaxHeaderBytes > 0, "--max-header-bytes")
+	add(opts.MaxBodyTime > 0, "--max-body-time")
+	add(opts.Http2 == "force", "--http2 force")
+	add(len(opts.HdrThreshold) > 0, "--header-threshold")
+	add(len(opts.ExpectHeader) > 0, "--expect-header")
+	add(len(opts.RequireHeaders) > 0, "--require-headers")
+	add(opts.ExpectAltSvc != "", "--expect-altsvc")
+	add(len(opts.ExpectAllow) > 0, "--expect-allow")
+	add(opts.AuthRealm != "", "--expect-auth-realm")
+	add(opts.HeaderOrder != "", "--expect-header-order")
+	add(opts.MaxClockSkew > 0, "--max-clock-skew")
+	add(opts.VaryEncoding, "--check-vary-encoding")
+	add(opts.ExpectChanged, "--expect-changed")
+	add(opts.ExpectSame, "--expect-unchanged")
+	add(opts.RequirePfs, "--require-pfs")
+	add(opts.ExpectCurve != "", "--expect-curve")
+	add(opts.MinChainLength > 0, "--min-chain-length")
+	add(opts.ChainLength > 0, "--expect-chain-length")
+	add(opts.CertDays != "", "--certificate")
+	add(len(opts.PinSPKI) > 0, "--pin-spki-sha256")
+	add(opts.ChainDays != "", "--check-chain-expiry")
+	return used
+}
+
+// okSummary describes what was asserted on an OK response for --verbose-ok.
+func okSummary(opts Options, resp *http.Response, elapsed time.Duration, timed bool) string {
+	summary := fmt.Sprintf("status code %d", resp.StatusCode)
+	if opts.Expect != "" {
+		summary += " expected"
+	}
+	if opts.ExpectNot != "" {
+		summary += " not in --expect-not"
+	}
+	if timed {
+		summary += fmt.Sprintf(", response time %s within %s", formatTime(elapsed.Seconds(), opts.Unit), formatTime(opts.Warn, opts.Unit))
+	}
+	if passed := append(responseOptions(opts), bodyOptions(opts)...); len(passed) > 0 {
+		summary += ", passed " + strings.Join(passed, ", ")
+	}
+	return summary
+}
+
+// formatTime formats seconds for a message in the --unit, e.g. 0.250s or
+// 250.0ms.
+func formatTime(seconds float64, unit string) string {
+	if unit == "ms" {
+		return fmt.Sprintf("%.1fms", seconds*1000)
+	}
+	return fmt.Sprintf("%.3fs", seconds)
+}
+
+// responseTime formats seconds for the status line in the --unit.
+func responseTime(seconds float64, unit string) string {
+	if unit == "ms" {
+		return fmt.Sprintf("%.1f millisecond", seconds*1000)
+	}
+	return fmt.Sprintf("%.3f second", seconds)
+}
+
+// timePerfdata returns the time perfdata in the --unit, with -w/-c as its
+// thresholds when withThresholds is set.
+func timePerfdata(seconds float64, opts Options, withThresholds bool) string {
+	scale, uom := 1.0, "s"
+	if opts.Unit == "ms" {
+		scale, uom = 1000, "ms"
+	}
+	thresholds := ";"
+	if withThresholds {
+		thresholds = fmt.Sprintf("%.6f;%.6f", opts.Warn*scale, opts.Crit*scale)
+	}
+	return fmt.Sprintf("time=%.6f%s;%s;%.6f", seconds*scale, uom, thresholds, 0.0)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// marshalJSONOutput round-trips out through encoding/json so that the
+// tests see the field names a consumer sees.
+func marshalJSONOutput(t *testing.T, out JSONOutput) map[string]interface{} {
+	t.Helper()
+	b, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestNewJSONOutput(t *testing.T) {
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	resp := &http.Response{
+		StatusCode: 200,
+		Proto:      "HTTP/1.1",
+		TLS: &tls.ConnectionState{
+			Version:     tls.VersionTLS13,
+			CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+			PeerCertificates: []*x509.Certificate{{
+				Subject:  pkix.Name{CommonName: "example.com"},
+				Issuer:   pkix.Name{CommonName: "Example CA"},
+				NotAfter: notAfter,
+			}},
+		},
+	}
+	var result Result
+	result.Add(NagiosWarning, "Unexpected http status code: %d", 404)
+	doc := marshalJSONOutput(t, newJSONOutput(result, "https://example.com/", resp, 42, 1500*time.Millisecond, []string{"time=1.5s"}))
+
+	if v := doc["schema_version"]; v != float64(schemaVersion) {
+		t.Errorf("schema_version = %v, want %d", v, schemaVersion)
+	}
+	if v := doc["status"]; v != "WARNING" {
+		t.Errorf("status = %v, want WARNING", v)
+	}
+	if v := doc["http_code"]; v != float64(200) {
+		t.Errorf("http_code = %v, want 200", v)
+	}
+
+	timings, ok := doc["timings"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("timings = %v, want an object", doc["timings"])
+	}
+	if v := timings["total"]; v != 1.5 {
+		t.Errorf("timings.total = %v, want 1.5", v)
+	}
+
+	tlsDoc, ok := doc["tls"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("tls = %v, want an object", doc["tls"])
+	}
+	if v := tlsDoc["version"]; v != "TLS 1.3" {
+		t.Errorf("tls.version = %v, want TLS 1.3", v)
+	}
+	if v := tlsDoc["cipher_suite"]; v != "TLS_AES_128_GCM_SHA256" {
+		t.Errorf("tls.cipher_suite = %v, want TLS_AES_128_GCM_SHA256", v)
+	}
+	certs, ok := tlsDoc["certificates"].([]interface{})
+	if !ok || len(certs) != 1 {
+		t.Fatalf("tls.certificates = %v, want one certificate", tlsDoc["certificates"])
+	}
+	cert := certs[0].(map[string]interface{})
+	if v := cert["subject"]; v != "CN=example.com" {
+		t.Errorf("certificate subject = %v, want CN=example.com", v)
+	}
+	if v := cert["not_after"]; v != "2030-01-02T03:04:05Z" {
+		t.Errorf("certificate not_after = %v, want 2030-01-02T03:04:05Z", v)
+	}
+
+	checks, ok := doc["checks"].([]interface{})
+	if !ok || len(checks) != 1 {
+		t.Fatalf("checks = %v, want one check", doc["checks"])
+	}
+	check := checks[0].(map[string]interface{})
+	if check["status"] != "WARNING" || check["message"] != "Unexpected http status code: 404" {
+		t.Errorf("checks[0] = %v", check)
+	}
+}
+
+func TestNewJSONOutputNoResponse(t *testing.T) {
+	doc := marshalJSONOutput(t, newJSONOutput(Result{}, "", nil, 0, 0, nil))
+
+	if v := doc["schema_version"]; v != float64(schemaVersion) {
+		t.Errorf("schema_version = %v, want %d", v, schemaVersion)
+	}
+	if _, ok := doc["tls"]; ok {
+		t.Errorf("tls = %v, want it omitted without a response", doc["tls"])
+	}
+	if _, ok := doc["timings"].(map[string]interface{}); !ok {
+		t.Errorf("timings = %v, want an object", doc["timings"])
+	}
+	// consumers iterate these, so they are empty arrays rather than null
+	for _, key := range []string{"checks", "messages", "perfdata"} {
+		if v, ok := doc[key].([]interface{}); !ok || len(v) != 0 {
+			t.Errorf("%s = %v, want []", key, doc[key])
+		}
+	}
+}
+
+func TestTimePerfdata(t *testing.T) {
+	tests := []struct {
+		unit           string
+		withThresholds bool
+		want           string
+	}{
+		{"s", false, "time=1.500000s;;;0.000000"},
+		{"s", true, "time=1.500000s;5.000000;10.000000;0.000000"},
+		{"ms", false, "time=1500.000000ms;;;0.000000"},
+		{"ms", true, "time=1500.000000ms;5000.000000;10000.000000;0.000000"},
+	}
+	for _, tt := range tests {
+		opts := Options{Unit: tt.unit, Warn: 5, Crit: 10}
+		if got := timePerfdata(1.5, opts, tt.withThresholds); got != tt.want {
+			t.Errorf("timePerfdata(%q, %v) = %q, want %q", tt.unit, tt.withThresholds, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// fetchPAC retrieves a proxy auto-config script from an http, https or
+// file URL.
+func fetchPAC(pacURL string, timeout time.Duration) (string, error) {
+	u, err := url.Parse(pacURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "file" {
+		data, err := ioutil.ReadFile(u.Path)
+		return string(data), err
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(pacURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s: %s", pacURL, resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// resolveIPv4 returns the first IPv4 address of host, or nil.
+func resolveIPv4(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4()
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+	}
+	return nil
+}
+
+// shExpMatch matches s against a shell expression where * matches any
+// sequence and ? a single character.
+func shExpMatch(s, shexp string) bool {
+	pattern := regexp.QuoteMeta(shexp)
+	pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+	pattern = strings.ReplaceAll(pattern, `\?`, ".")
+	matched, _ := regexp.MatchString("^"+pattern+"$", s)
+	return matched
+}
+
+// pacFunctions are the predefined functions available to a PAC script.
+// The date and time functions are not provided.
+func pacFunctions(vm *goja.Runtime) {
+	vm.Set("isPlainHostName", func(host string) bool {
+		return !strings.Contains(host, ".")
+	})
+	vm.Set("dnsDomainIs", func(host, domain string) bool {
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(domain))
+	})
+	vm.Set("localHostOrDomainIs", func(host, hostdom string) bool {
+		host, hostdom = strings.ToLower(host), strings.ToLower(hostdom)
+		return host == hostdom || (!strings.Contains(host, ".") && strings.HasPrefix(hostdom, host+"."))
+	})
+	vm.Set("isResolvable", func(host string) bool {
+		return resolveIPv4(host) != nil
+	})
+	vm.Set("isInNet", func(host, pattern, mask string) bool {
+		ip := resolveIPv4(host)
+		p := net.ParseIP(pattern).To4()
+		m := net.ParseIP(mask).To4()
+		if ip == nil || p == nil || m == nil {
+			return false
+		}
+		return ip.Mask(net.IPMask(m)).Equal(p.Mask(net.IPMask(m)))
+	})
+	vm.Set("dnsResolve", func(host string) interface{} {
+		if ip := resolveIPv4(host); ip != nil {
+			return ip.String()
+		}
+		return nil
+	})
+	vm.Set("myIpAddress", func() string {
+		// no packet is sent, this only selects the outgoing address
+		conn, err := net.Dial("udp", "192.0.2.1:80")
+		if err != nil {
+			return "127.0.0.1"
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).IP.String()
+	})
+	vm.Set("dnsDomainLevels", func(host string) int {
+		return strings.Count(host, ".")
+	})
+	vm.Set("shExpMatch", shExpMatch)
+}
+
+// evalPAC runs FindProxyForURL of script for target and returns its result,
+// e.g. "PROXY proxy:3128; DIRECT". A script still running after timeout,
+// such as one looping forever, is interrupted.
+func evalPAC(script, target, host string, timeout time.Duration) (string, error) {
+	vm := goja.New()
+	pacFunctions(vm)
+	timer := time.AfterFunc(timeout, func() {
+		vm.Interrupt("timeout")
+	})
+	defer timer.Stop()
+	v, err := runPAC(vm, script, target, host)
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return "", fmt.Errorf("PAC script did not return within %s", timeout)
+	}
+	return v, err
+}
+
+func runPAC(vm *goja.Runtime, script, target, host string) (string, error) {
+	if _, err := vm.RunString(script); err != nil {
+		return "", err
+	}
+	find, ok := goja.AssertFunction(vm.Get("FindProxyForURL"))
+	if !ok {
+		return "", fmt.Errorf("PAC script does not define FindProxyForURL")
+	}
+	v, err := find(goja.Undefined(), vm.ToValue(target), vm.ToValue(host))
+	if err != nil {
+		return "", err
+	}
+	return v.String(), nil
+}
+
+// pacProxy converts the first entry of a FindProxyForURL result into a
+// proxy URL. DIRECT yields an empty string.
+func pacProxy(result string) (string, error) {
+	entry := strings.TrimSpace(strings.Split(result, ";")[0])
+	fields := strings.Fields(entry)
+	if len(fields) == 1 && strings.EqualFold(fields[0], "DIRECT") {
+		return "", nil
+	}
+	if len(fields) != 2 {
+		return "", fmt.Errorf("invalid PAC result: %s", result)
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "PROXY", "HTTP":
+		return "http://" + fields[1], nil
+	case "HTTPS":
+		return "https://" + fields[1], nil
+	case "SOCKS", "SOCKS5":
+		return "socks5://" + fields[1], nil
+	}
+	return "", fmt.Errorf("unsupported PAC result: %s", result)
+}
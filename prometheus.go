@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// promSelector selects series by metric name and a subset of labels.
+type promSelector struct {
+	Name   string
+	Labels map[string]string
+}
+
+var (
+	promSelectorRe = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)\s*(?:\{(.*)\})?$`)
+	promLabelRe    = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*=\s*"((?:[^"\\]|\\.)*)"\s*(?:,|$)`)
+)
+
+// parsePromSelector parses a selector like `up{job="api"}`.
+func parsePromSelector(s string) (promSelector, error) {
+	m := promSelectorRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return promSelector{}, fmt.Errorf("invalid metric selector: %s", s)
+	}
+	sel := promSelector{Name: m[1], Labels: map[string]string{}}
+	rest := m[2]
+	for strings.TrimSpace(rest) != "" {
+		l := promLabelRe.FindStringSubmatch(rest)
+		if l == nil {
+			return promSelector{}, fmt.Errorf("invalid metric selector: %s", s)
+		}
+		value, err := strconv.Unquote(`"` + l[2] + `"`)
+		if err != nil {
+			return promSelector{}, fmt.Errorf("invalid metric selector: %s", s)
+		}
+		sel.Labels[l[1]] = value
+		rest = rest[len(l[0]):]
+	}
+	return sel, nil
+}
+
+// comparisonOps maps the operators accepted in expectations to their
+// canonical names.
+var comparisonOps = []struct {
+	symbol string
+	name   string
+}{
+	{">=", "ge"},
+	{"<=", "le"},
+	{"!=", "ne"},
+	{"==", "eq"},
+	{">", "gt"},
+	{"<", "lt"},
+	{"=", "eq"},
+}
+
+// parseComparison parses an expectation like ">=1". A bare number means
+// equality.
+func parseComparison(s string) (op string, value float64, err error) {
+	s = strings.TrimSpace(s)
+	op = "eq"
+	for _, o := range comparisonOps {
+		if strings.HasPrefix(s, o.symbol) {
+			op = o.name
+			s = strings.TrimSpace(s[len(o.symbol):])
+			break
+		}
+	}
+	value, err = strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid expectation: %s", s)
+	}
+	return op, value, nil
+}
+
+// compareFloat reports whether "a op b" holds.
+func compareFloat(op string, a, b float64) bool {
+	switch op {
+	case "eq":
+		return a == b
+	case "ne":
+		return a != b
+	case "lt":
+		return a < b
+	case "le":
+		return a <= b
+	case "gt":
+		return a > b
+	case "ge":
+		return a >= b
+	}
+	return false
+}
+
+// promSample is the value of one series matched by a selector.
+type promSample struct {
+	// Series is the name with every label, e.g. up{instance="a",job="api"}.
+	Series string
+	// Extra are the values of the labels not fixed by the selector, which
+	// tell the matched series apart.
+	Extra []string
+	Value float64
+}
+
+// promSamples returns every series in families matching sel, sorted by
+// series. The _sum and _count series of summaries and histograms are
+// addressable by their suffixed names.
+func promSamples(families map[string]*dto.MetricFamily, sel promSelector) []promSample {
+	var samples []promSample
+	for name, mf := range families {
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch {
+			case name == sel.Name && m.Gauge != nil:
+				value = m.Gauge.GetValue()
+			case name == sel.Name && m.Counter != nil:
+				value = m.Counter.GetValue()
+			case name == sel.Name && m.Untyped != nil:
+				value = m.Untyped.GetValue()
+			case name+"_sum" == sel.Name && m.Summary != nil:
+				value = m.Summary.GetSampleSum()
+			case name+"_count" == sel.Name && m.Summary != nil:
+				value = float64(m.Summary.GetSampleCount())
+			case name+"_sum" == sel.Name && m.Histogram != nil:
+				value = m.Histogram.GetSampleSum()
+			case name+"_count" == sel.Name && m.Histogram != nil:
+				value = float64(m.Histogram.GetSampleCount())
+			default:
+				continue
+			}
+			if promLabelsMatch(m.GetLabel(), sel.Labels) {
+				samples = append(samples, newPromSample(sel, m.GetLabel(), value))
+			}
+		}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Series < samples[j].Series })
+	return samples
+}
+
+func newPromSample(sel promSelector, pairs []*dto.LabelPair, value float64) promSample {
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+	sample := promSample{Value: value}
+	var labels []string
+	for _, p := range pairs {
+		labels = append(labels, fmt.Sprintf("%s=%q", p.GetName(), p.GetValue()))
+		if _, fixed := sel.Labels[p.GetName()]; !fixed {
+			sample.Extra = append(sample.Extra, p.GetValue())
+		}
+	}
+	sample.Series = sel.Name
+	if len(labels) > 0 {
+		sample.Series += "{" + strings.Join(labels, ",") + "}"
+	}
+	return sample
+}
+
+// perfdataLabel names the prom_value perfdata of a sample. A single match
+// keeps the plain name, several are told apart by their extra labels.
+func (s promSample) perfdataLabel(single bool) string {
+	if single || len(s.Extra) == 0 {
+		return "prom_value"
+	}
+	label := "prom_value_" + strings.Join(s.Extra, "_")
+	label = strings.NewReplacer("=", "_", "'", "_").Replace(label)
+	if strings.ContainsAny(label, " \t") {
+		label = "'" + label + "'"
+	}
+	return label
+}
+
+func promLabelsMatch(pairs []*dto.LabelPair, want map[string]string) bool {
+	for k, v := range want {
+		found := false
+		for _, p := range pairs {
+			if p.GetName() == k && p.GetValue() == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPrometheus parses body in the Prometheus text format and compares
+// every series matching selector against the expectation, adding perfdata
+// for each of them.
+func checkPrometheus(body []byte, selector, expect string, result *Result) {
+	sel, err := parsePromSelector(selector)
+	if err != nil {
+		result.Add(NagiosUnknown, "%s", err)
+		return
+	}
+	op, want, err := parseComparison(expect)
+	if err != nil {
+		result.Add(NagiosUnknown, "%s", err)
+		return
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		result.Add(NagiosCritical, "failed to parse metrics: %s", err)
+		return
+	}
+	samples := promSamples(families, sel)
+	if len(samples) == 0 {
+		result.Add(NagiosCritical, "metric %s not found", selector)
+		return
+	}
+	labels := map[string]bool{}
+	for _, sample := range samples {
+		labels[sample.perfdataLabel(len(samples) == 1)] = true
+	}
+	if len(labels) != len(samples) {
+		result.Add(NagiosUnknown, "metric %s matches %d series which cannot be told apart", selector, len(samples))
+		return
+	}
+	for _, sample := range samples {
+		if !compareFloat(op, sample.Value, want) {
+			result.Add(NagiosCritical, "metric %s is %g, expected %s", sample.Series, sample.Value, expect)
+		}
+		result.Perfdata = append(result.Perfdata, fmt.Sprintf("%s=%g", sample.perfdataLabel(len(samples) == 1), sample.Value))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePromSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     promSelector
+		ok       bool
+	}{
+		{"up", promSelector{Name: "up", Labels: map[string]string{}}, true},
+		{`up{job="api"}`, promSelector{Name: "up", Labels: map[string]string{"job": "api"}}, true},
+		{`up{job="api", instance="a:9100"}`, promSelector{Name: "up", Labels: map[string]string{"job": "api", "instance": "a:9100"}}, true},
+		{`up{path="say \"hi\""}`, promSelector{Name: "up", Labels: map[string]string{"path": `say "hi"`}}, true},
+		{`up{job=api}`, promSelector{}, false},
+		{`9up`, promSelector{}, false},
+		{`up{job="api"`, promSelector{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parsePromSelector(tt.selector)
+		if (err == nil) != tt.ok {
+			t.Errorf("parsePromSelector(%q) error = %v, want ok %v", tt.selector, err, tt.ok)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePromSelector(%q) = %+v, want %+v", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestParseComparison(t *testing.T) {
+	tests := []struct {
+		expect string
+		op     string
+		value  float64
+		ok     bool
+	}{
+		{"1", "eq", 1, true},
+		{">=0.5", "ge", 0.5, true},
+		{"< 10", "lt", 10, true},
+		{"!=0", "ne", 0, true},
+		{"==2", "eq", 2, true},
+		{">", "", 0, false},
+		{"abc", "", 0, false},
+	}
+	for _, tt := range tests {
+		op, value, err := parseComparison(tt.expect)
+		if (err == nil) != tt.ok || op != tt.op || value != tt.value {
+			t.Errorf("parseComparison(%q) = %q, %v, %v, want %q, %v, ok %v", tt.expect, op, value, err, tt.op, tt.value, tt.ok)
+		}
+	}
+}
+
+const promFixture = `# TYPE up gauge
+up{job="api",instance="b"} 0
+up{job="api",instance="a"} 1
+up{job="db",instance="a"} 1
+# TYPE http_requests_total counter
+http_requests_total 1027
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.05
+rpc_duration_seconds_sum 17.5
+rpc_duration_seconds_count 350
+`
+
+func TestCheckPrometheus(t *testing.T) {
+	tests := []struct {
+		selector, expect string
+		status           int
+		perfdata         []string
+	}{
+		{"http_requests_total", ">1000", NagiosOk, []string{"prom_value=1027"}},
+		{`up{job="db"}`, "1", NagiosOk, []string{"prom_value=1"}},
+		{"rpc_duration_seconds_count", "350", NagiosOk, []string{"prom_value=350"}},
+		{"rpc_duration_seconds_sum", "<10", NagiosCritical, []string{"prom_value=17.5"}},
+		// every match is reported, sorted by series
+		{`up{job="api"}`, "1", NagiosCritical, []string{"prom_value_a=1", "prom_value_b=0"}},
+		{"up", ">=0", NagiosOk, []string{"prom_value_a_api=1", "prom_value_a_db=1", "prom_value_b_api=0"}},
+		{"missing", "1", NagiosCritical, nil},
+	}
+	for _, tt := range tests {
+		var result Result
+		checkPrometheus([]byte(promFixture), tt.selector, tt.expect, &result)
+		if result.Status != tt.status {
+			t.Errorf("checkPrometheus(%q, %q) = %s %v, want %s", tt.selector, tt.expect, statusString(result.Status), result.Messages, statusString(tt.status))
+		}
+		if !reflect.DeepEqual(result.Perfdata, tt.perfdata) {
+			t.Errorf("checkPrometheus(%q, %q) perfdata = %v, want %v", tt.selector, tt.expect, result.Perfdata, tt.perfdata)
+		}
+	}
+}
+
+func TestCheckPrometheusAmbiguous(t *testing.T) {
+	body := "# TYPE up gauge\nup{a=\"x_y\"} 1\nup{a=\"x\",b=\"y\"} 1\n"
+	var result Result
+	checkPrometheus([]byte(body), "up", "1", &result)
+	if result.Status != NagiosUnknown {
+		t.Errorf("status = %s %v, want UNKNOWN", statusString(result.Status), result.Messages)
+	}
+}
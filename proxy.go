@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// loadCertPool reads PEM encoded CA certificates from path.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// proxyTLSConfig returns the TLS configuration for the connection to an
+// https proxy. The proxy certificate is verified against caFile, or the
+// system roots without it, unless insecure is set as for the target.
+func proxyTLSConfig(proxy *url.URL, caFile string, insecure bool) (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         proxy.Hostname(),
+		InsecureSkipVerify: insecure,
+	}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = pool
+	}
+	return conf, nil
+}
+
+// proxyDialTLS returns a dialer for the transport's DialTLSContext which,
+// as a proxy is set, is only used to connect to the https proxy itself. The
+// target's TLS is still set up with the transport's TLSClientConfig.
+func proxyDialTLS(dial func(ctx context.Context, network, addr string) (net.Conn, error), conf *tls.Config) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tc := tls.Client(conn, conf)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("proxy TLS handshake with %s failed: %s", addr, err)
+		}
+		return tc, nil
+	}
+}
+
+// isProxyConnectError reports whether err was returned while connecting to
+// the proxy rather than to the target.
+func isProxyConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "proxyconnect"
+}
+
+// environmentProxy returns the proxy used without --proxy or --pac-url,
+// taken from HTTP_PROXY, HTTPS_PROXY and NO_PROXY. It is nil for the modes
+// which have to reach the target directly: --expect-header-order records
+// the connection, --connect-only measures it and --ssh-jump tunnels it.
+func environmentProxy(opts Options) func(*http.Request) (*url.URL, error) {
+	if opts.HeaderOrder != "" || opts.ConnectOnly || opts.SshJump != "" {
+		return nil
+	}
+	return http.ProxyFromEnvironment
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestEnvironmentProxy(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		direct bool
+	}{
+		{"plain request", Options{}, false},
+		{"--expect-header-order", Options{HeaderOrder: "Content-Type"}, true},
+		{"--connect-only", Options{ConnectOnly: true}, true},
+		{"--ssh-jump", Options{SshJump: "bastion"}, true},
+	}
+	for _, tt := range tests {
+		if got := environmentProxy(tt.opts); (got == nil) != tt.direct {
+			t.Errorf("%s: environmentProxy is nil = %v, want %v", tt.name, got == nil, tt.direct)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// redirectHop is a redirect response received while following redirects.
+type redirectHop struct {
+	URL        string
+	StatusCode int
+	Location   string
+}
+
+// redirectPolicy returns a CheckRedirect function. Without follow the
+// first response is returned as is, otherwise every redirect response is
+// appended to hops and more than max redirects are an error, as is with
+// sticky a redirect to another host.
+func redirectPolicy(follow, sticky bool, max int, hops *[]redirectHop) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		// https://jonathanmh.com/tracing-preventing-http-redirects-golang/
+		if !follow {
+			return http.ErrUseLastResponse
+		}
+		if req.Response != nil {
+			*hops = append(*hops, redirectHop{
+				URL:        via[len(via)-1].URL.String(),
+				StatusCode: req.Response.StatusCode,
+				Location:   req.Response.Header.Get("Location"),
+			})
+		}
+		if len(via) > max {
+			return errors.New("stopped after " + strconv.Itoa(max) + " redirects")
+		}
+		if sticky && !sameHost(req.URL, via[0]) {
+			return fmt.Errorf("redirected to another host %s", req.URL.Host)
+		}
+		return nil
+	}
+}
+
+// checkIntermediate requires every redirect hop to have one of the
+// expected status codes.
+func checkIntermediate(hops []redirectHop, expect []string, result *Result) {
+	for i, hop := range hops {
+		code := strconv.Itoa(hop.StatusCode)
+		matched := false
+		for _, e := range expect {
+			if code == e {
+				matched = true
+			}
+		}
+		if !matched {
+			result.Add(NagiosCritical, "redirect hop %d (%s) returned %d, expected %s", i+1, hop.URL, hop.StatusCode, strings.Join(expect, ","))
+		}
+	}
+}
+
+// checkNoRedirect reports a redirect response, or with --follow every
+// redirect that was followed, together with its Location.
+func checkNoRedirect(resp *http.Response, hops []redirectHop, result *Result) {
+	for _, hop := range hops {
+		result.Add(NagiosCritical, "%s redirected with %d to %s", hop.URL, hop.StatusCode, hop.Location)
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		result.Add(NagiosCritical, "%s redirected with %d to %s", resp.Request.URL, resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+// checkRedirectCount warns when more than max redirects were followed and
+// adds the number of hops as perfdata.
+func checkRedirectCount(hops []redirectHop, max int, result *Result) {
+	if len(hops) > max {
+		result.Add(NagiosWarning, "followed %d redirects, more than %d", len(hops), max)
+	}
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("redirects=%d;%d;;0", len(hops), max))
+}
+
+// checkRedirectBody reports WARNING when a redirect response carries a
+// body larger than max bytes, such as an error page sent with a Location.
+func checkRedirectBody(statusCode int, size, max int64, result *Result) {
+	if !isRedirect(statusCode) {
+		return
+	}
+	if size > max {
+		result.Add(NagiosWarning, "%d redirect has a %d byte body, more than %d", statusCode, size, max)
+	}
+}
+
+// isRedirect reports whether statusCode is a redirect, i.e. 3xx other than
+// 304 Not Modified.
+func isRedirect(statusCode int) bool {
+	return statusCode >= 300 && statusCode < 400 && statusCode != http.StatusNotModified
+}
+
+// sameHost reports whether target is on the host of the first request,
+// either its address or its Host header.
+func sameHost(target *url.URL, first *http.Request) bool {
+	host := target.Hostname()
+	return host == first.URL.Hostname() || (first.Host != "" && host == hostname(first.Host))
+}
+
+// checkRedirectLocation requires the Location of a redirect response to be
+// expected, or to start with it when expected ends with *.
+func checkRedirectLocation(resp *http.Response, expected string, result *Result) {
+	if !isRedirect(resp.StatusCode) {
+		return
+	}
+	location := resp.Header.Get("Location")
+	if prefix := strings.TrimSuffix(expected, "*"); prefix != expected {
+		if !strings.HasPrefix(location, prefix) {
+			result.Add(NagiosCritical, "Location %q does not start with %q", location, prefix)
+		}
+	} else if location != expected {
+		result.Add(NagiosCritical, "Location %q, expected %q", location, expected)
+	}
+}
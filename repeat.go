@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// repeatChildEnv marks a process started by --repeat so that it runs the
+// check only once.
+const repeatChildEnv = "CHECK_HTTP_GO_REPEAT_CHILD"
+
+// runRepeat runs the check opts.Repeat times by executing itself with the
+// same arguments, prefixing each output line with a timestamp, and exits
+// with the worst status seen. An interrupt stops the loop early.
+func runRepeat(opts Options) {
+	self, err := os.Executable()
+	if err != nil {
+		fail(opts, NagiosUnknown, "%s", err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	worst := NagiosOk
+	for i := 0; i < opts.Repeat; i++ {
+		if i > 0 {
+			select {
+			case <-interrupt:
+				exit(opts, worst)
+			case <-time.After(time.Duration(opts.Interval * float64(time.Second))):
+			}
+		}
+
+		now := time.Now()
+		cmd := exec.Command(self, os.Args[1:]...)
+		cmd.Env = append(os.Environ(), repeatChildEnv+"=1")
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+
+		select {
+		case <-interrupt:
+			exit(opts, worst)
+		default:
+		}
+
+		status := NagiosOk
+		if err != nil {
+			status = NagiosUnknown
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+				status = exitErr.ExitCode()
+			}
+		}
+		if severity[status] > severity[worst] {
+			worst = status
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			if opts.Output == "json" {
+				fmt.Println(scanner.Text())
+			} else {
+				fmt.Printf("%s %s\n", now.Format(time.RFC3339), scanner.Text())
+			}
+		}
+	}
+	exit(opts, worst)
+}
@@ -0,0 +1,13 @@
+//go:build !plan9
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isConnReset reports whether the peer reset the connection.
+func isConnReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET)
+}
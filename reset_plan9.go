@@ -0,0 +1,9 @@
+package main
+
+import "strings"
+
+// isConnReset reports whether the peer reset the connection. Plan 9 has no
+// errno, the reset is only told by its message.
+func isConnReset(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "connection reset")
+}
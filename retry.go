@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// retryChildEnv is set to the number of retries so far in a process
+// started by --retries, which then runs the check only once.
+const retryChildEnv = "CHECK_HTTP_GO_RETRY_CHILD"
+
+// retryCount returns how many attempts preceded this one.
+func retryCount() int {
+	n, _ := strconv.Atoi(os.Getenv(retryChildEnv))
+	return n
+}
+
+// finalAttempt reports whether an attempt ending with status is the one
+// whose result is reported, so that its side effects such as syslog and the
+// state file happen once. Without --retries every run is final.
+func finalAttempt(opts Options, status int) bool {
+	if os.Getenv(retryChildEnv) == "" {
+		return true
+	}
+	return status != NagiosCritical || retryCount() >= opts.Retries
+}
+
+// runRetries runs the check by executing itself with the same arguments
+// until it is not CRITICAL or opts.Retries retries have been made, then
+// prints the output of the last attempt and exits with its status.
+func runRetries(opts Options) {
+	self, err := os.Executable()
+	if err != nil {
+		fail(opts, NagiosUnknown, "%s", err)
+	}
+
+	for i := 0; ; i++ {
+		cmd := exec.Command(self, os.Args[1:]...)
+		cmd.Env = append(os.Environ(), retryChildEnv+"="+strconv.Itoa(i))
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+
+		status := NagiosOk
+		if err != nil {
+			status = NagiosUnknown
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+				status = exitErr.ExitCode()
+			}
+		}
+		if status != NagiosCritical || i == opts.Retries {
+			if i > 0 && opts.Output != "json" && !opts.PerfdataOnly {
+				out = retryNote(out, i)
+			}
+			os.Stdout.Write(out)
+			os.Exit(status)
+		}
+		time.Sleep(time.Duration(opts.RetryInterval * float64(time.Second)))
+	}
+}
+
+// retryNote adds "(after n retries)" to the first line of out, before the
+// perfdata.
+func retryNote(out []byte, n int) []byte {
+	note := fmt.Sprintf(" (after %d retries)", n)
+	if n == 1 {
+		note = " (after 1 retry)"
+	}
+	line := out
+	if i := bytes.IndexByte(out, '\n'); i >= 0 {
+		line = out[:i]
+	}
+	at := len(line)
+	if i := bytes.Index(line, []byte(" |")); i >= 0 {
+		at = i
+	}
+	return append(append(append([]byte{}, out[:at]...), note...), out[at:]...)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestFinalAttempt(t *testing.T) {
+	tests := []struct {
+		name   string
+		child  string
+		status int
+		final  bool
+	}{
+		{"without --retries", "", NagiosCritical, true},
+		{"first attempt OK", "0", NagiosOk, true},
+		{"first attempt WARNING", "0", NagiosWarning, true},
+		{"first attempt CRITICAL", "0", NagiosCritical, false},
+		{"second attempt CRITICAL", "1", NagiosCritical, false},
+		{"last attempt CRITICAL", "2", NagiosCritical, true},
+	}
+	opts := Options{Retries: 2}
+	for _, tt := range tests {
+		t.Setenv(retryChildEnv, tt.child)
+		if got := finalAttempt(opts, tt.status); got != tt.final {
+			t.Errorf("%s: finalAttempt = %v, want %v", tt.name, got, tt.final)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// sitemap covers both a <urlset> and a <sitemapindex> document.
+type sitemap struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// checkSitemap parses body as an XML sitemap and reports the number of
+// entries and any whose <loc> is not an absolute URL.
+func checkSitemap(body []byte, result *Result) {
+	var s sitemap
+	if err := xml.Unmarshal(body, &s); err != nil {
+		result.Add(NagiosCritical, "invalid sitemap: %s", err)
+		return
+	}
+	entries := s.URLs
+	switch s.XMLName.Local {
+	case "urlset":
+	case "sitemapindex":
+		entries = s.Sitemaps
+	default:
+		result.Add(NagiosCritical, "invalid sitemap: unexpected root element <%s>", s.XMLName.Local)
+		return
+	}
+
+	malformed := 0
+	var details []string
+	for i, e := range entries {
+		loc := strings.TrimSpace(e.Loc)
+		u, err := url.Parse(loc)
+		if loc == "" || err != nil || !u.IsAbs() || u.Host == "" {
+			malformed++
+			if malformed <= 5 {
+				details = append(details, fmt.Sprintf("sitemap entry %d has malformed loc `%s`", i+1, loc))
+			}
+		}
+	}
+	result.Perfdata = append(result.Perfdata, fmt.Sprintf("sitemap_urls=%d;;;0", len(entries)))
+	if malformed > 0 {
+		result.Add(NagiosWarning, "%d of %d sitemap entries are malformed", malformed, len(entries))
+		result.Messages = append(result.Messages, details...)
+	}
+}
+
+// checkRobots requires every directive in required to appear in the
+// robots.txt body. Directive names are case-insensitive.
+func checkRobots(body []byte, required []string, result *Result) {
+	found := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 {
+			found[strings.ToLower(strings.TrimSpace(kv[0]))] = true
+		}
+	}
+	for _, directive := range required {
+		if !found[strings.ToLower(directive)] {
+			result.Add(NagiosCritical, "robots.txt has no %s directive", directive)
+		}
+	}
+}
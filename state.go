@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// fingerprint identifies a response for change detection between runs.
+type fingerprint struct {
+	Sha256 string    `json:"sha256,omitempty"`
+	ETag   string    `json:"etag,omitempty"`
+	Size   int64     `json:"size"`
+	Time   time.Time `json:"time"`
+}
+
+func newFingerprint(body []byte, etag string, size int64, noBody bool) fingerprint {
+	f := fingerprint{ETag: etag, Size: size, Time: time.Now()}
+	if !noBody {
+		sum := sha256.Sum256(body)
+		f.Sha256 = hex.EncodeToString(sum[:])
+	}
+	return f
+}
+
+// differences lists the fingerprint fields which changed since prev.
+func (f fingerprint) differences(prev fingerprint) []string {
+	var diff []string
+	if f.Sha256 != prev.Sha256 {
+		diff = append(diff, "sha256")
+	}
+	if f.ETag != prev.ETag {
+		diff = append(diff, "etag")
+	}
+	if f.Size != prev.Size {
+		diff = append(diff, "size")
+	}
+	return diff
+}
+
+// checkStateFile compares the response with the fingerprint stored by the
+// previous run. By default a change is a WARNING; expectChanged inverts
+// that, and an explicit expectation raises the state to CRITICAL. The
+// current fingerprint is recorded separately by writeStateFile, and only if
+// checkStateFile returns true; a state file it cannot read is left alone.
+func checkStateFile(path string, current fingerprint, expectChanged, expectUnchanged bool, result *Result) bool {
+	var prev fingerprint
+	data, err := ioutil.ReadFile(path)
+	first := os.IsNotExist(err)
+	if err != nil && !first {
+		result.Add(NagiosUnknown, "state file: %s", err)
+		return false
+	}
+	if !first {
+		if err := json.Unmarshal(data, &prev); err != nil {
+			result.Add(NagiosUnknown, "state file %s: %s", path, err)
+			return false
+		}
+	}
+
+	if first {
+		result.Messages = append(result.Messages, "state recorded in "+path)
+		return true
+	}
+
+	diff := current.differences(prev)
+	since := prev.Time.Format(time.RFC3339)
+	switch {
+	case expectChanged && len(diff) == 0:
+		result.Add(NagiosCritical, "response unchanged since last run at %s", since)
+	case !expectChanged && len(diff) > 0:
+		state := NagiosWarning
+		if expectUnchanged {
+			state = NagiosCritical
+		}
+		result.Add(state, "response changed since last run at %s (%s)", since, strings.Join(diff, ", "))
+	}
+	return true
+}
+
+// writeStateFile records current for the next run to compare with.
+func writeStateFile(path string, current fingerprint) error {
+	data, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,71 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+	"os"
+	"strconv"
+)
+
+// syslogFacilities maps the --syslog-facility choices to priorities.
+var syslogFacilities = map[string]syslog.Priority{
+	"user":   syslog.LOG_USER,
+	"daemon": syslog.LOG_DAEMON,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// validSyslogFacility reports whether name is a --syslog-facility choice.
+func validSyslogFacility(name string) bool {
+	_, ok := syslogFacilities[name]
+	return ok
+}
+
+// syslogTarget names the checked endpoint in syslog messages.
+func syslogTarget(opts Options) string {
+	host := opts.Vhost
+	if host == "" {
+		host = opts.Ipaddr
+	}
+	return hostLiteral(host) + ":" + strconv.Itoa(opts.Port) + opts.Uri
+}
+
+// logResult writes the result line to the local syslog when --syslog is
+// given. A syslog failure is reported on stderr and does not change the
+// result of the check.
+func logResult(opts Options, status int, line string) {
+	if !opts.Syslog || !finalAttempt(opts, status) {
+		return
+	}
+	severity := syslog.LOG_INFO
+	switch status {
+	case NagiosWarning:
+		severity = syslog.LOG_WARNING
+	case NagiosCritical:
+		severity = syslog.LOG_CRIT
+	case NagiosUnknown:
+		severity = syslog.LOG_ERR
+	}
+	facility, ok := syslogFacilities[opts.SyslogFacility]
+	if !ok {
+		// an unknown facility is reported on stdout instead
+		return
+	}
+	w, err := syslog.New(facility|severity, "check_http_go")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "syslog: %s\n", err)
+		return
+	}
+	defer w.Close()
+	if _, err := fmt.Fprintf(w, "%s %s", syslogTarget(opts), line); err != nil {
+		fmt.Fprintf(os.Stderr, "syslog: %s\n", err)
+	}
+}
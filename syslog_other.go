@@ -0,0 +1,20 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// validSyslogFacility accepts any facility, there is no syslog to log to.
+func validSyslogFacility(name string) bool {
+	return true
+}
+
+// logResult notes on stderr that --syslog has no effect on this platform.
+func logResult(opts Options, status int, line string) {
+	if opts.Syslog {
+		fmt.Fprintln(os.Stderr, "syslog not supported")
+	}
+}
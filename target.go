@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// applyURL fills the target options from a full URL, overriding --ipaddr,
+// --port, --uri and --ssl. --vhost still sets the Host header when given.
+// The userinfo of the URL, if any, is returned for basic authentication.
+func applyURL(opts *Options, raw string) (*url.Userinfo, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme: %s", raw)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("no host in URL: %s", raw)
+	}
+
+	opts.Ssl = u.Scheme == "https"
+	opts.Ipaddr = u.Hostname()
+	if opts.Vhost == "" {
+		opts.Vhost = u.Hostname()
+	}
+	// without a port in the URL the default of the scheme is used
+	opts.Port = 0
+	if u.Port() != "" {
+		opts.Port, err = strconv.Atoi(u.Port())
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in URL: %s", raw)
+		}
+	}
+	opts.Uri = u.RequestURI()
+	if opts.RawPath {
+		opts.Uri = rawRequestURI(raw)
+	}
+	return u.User, nil
+}
+
+// rawRequestURI returns the path and query of raw as written, without the
+// normalization of url.URL.RequestURI.
+func rawRequestURI(raw string) string {
+	rest := raw[strings.Index(raw, "://")+3:]
+	if i := strings.IndexByte(rest, '#'); i >= 0 {
+		rest = rest[:i]
+	}
+	i := strings.IndexAny(rest, "/?")
+	if i < 0 {
+		return "/"
+	}
+	if rest[i] == '?' {
+		return "/" + rest[i:]
+	}
+	return rest[i:]
+}
+
+// setRawPath makes req send the path of uri exactly as given. Go otherwise
+// re-escapes a path it considers invalid, which also decodes %2F to a slash.
+func setRawPath(req *http.Request, uri string) {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	req.URL.Opaque = uri
+}
+
+// targetURL builds the request URL, bracketing an IPv6 host.
+func targetURL(scheme, host string, port int, uri string) string {
+	return scheme + "://" + net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(port)) + uri
+}
+
+// hostLiteral puts an IPv6 address in brackets as a URL or a Host header
+// requires. Anything else, including host:port, is returned as is.
+func hostLiteral(host string) string {
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil && ip.To4() == nil {
+		return "[" + ip.String() + "]"
+	}
+	return host
+}
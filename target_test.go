@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostLiteral(t *testing.T) {
+	tests := []struct {
+		host, want string
+	}{
+		{"::1", "[::1]"},
+		{"[::1]", "[::1]"},
+		{"2001:db8::0001", "[2001:db8::1]"},
+		{"127.0.0.1", "127.0.0.1"},
+		{"example.com", "example.com"},
+		{"example.com:8080", "example.com:8080"},
+	}
+	for _, tt := range tests {
+		if got := hostLiteral(tt.host); got != tt.want {
+			t.Errorf("hostLiteral(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestTargetURL(t *testing.T) {
+	tests := []struct {
+		scheme, host string
+		port         int
+		uri, want    string
+	}{
+		{"http", "::1", 8080, "/", "http://[::1]:8080/"},
+		{"https", "[::1]", 443, "/health", "https://[::1]:443/health"},
+		{"http", "127.0.0.1", 80, "/?a=b", "http://127.0.0.1:80/?a=b"},
+		{"https", "example.com", 8443, "/", "https://example.com:8443/"},
+	}
+	for _, tt := range tests {
+		if got := targetURL(tt.scheme, tt.host, tt.port, tt.uri); got != tt.want {
+			t.Errorf("targetURL(%q, %q, %d, %q) = %q, want %q", tt.scheme, tt.host, tt.port, tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestRawPathRequestURI(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.RequestURI
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		raw, want string
+	}{
+		{srv.URL + "/a%2Fb/c", "/a%2Fb/c"},
+		// without setRawPath Go re-escapes this path, decoding %2F
+		{srv.URL + "/a%2Fb/{x}", "/a%2Fb/{x}"},
+		{srv.URL + "/a%2fb?x=%2F", "/a%2fb?x=%2F"},
+		{srv.URL + "/a%2Fb#frag", "/a%2Fb"},
+		{srv.URL + "?q=1", "/?q=1"},
+	}
+	for _, tt := range tests {
+		var opts Options
+		opts.RawPath = true
+		if _, err := applyURL(&opts, tt.raw); err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest("GET", targetURL("http", opts.Ipaddr, opts.Port, opts.Uri), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		setRawPath(req, opts.Uri)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got != tt.want {
+			t.Errorf("%s sent %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expandTemplate replaces the substitution tokens in a request body:
+//
+//	{{now}}      current time in RFC 3339 (UTC)
+//	{{unixtime}} current time in seconds since the epoch
+//	{{uuid}}     random version 4 UUID
+func expandTemplate(s string, now time.Time) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	uuid, err := newUUID()
+	if err != nil {
+		return "", err
+	}
+	return strings.NewReplacer(
+		"{{now}}", now.UTC().Format(time.RFC3339),
+		"{{unixtime}}", strconv.FormatInt(now.Unix(), 10),
+		"{{uuid}}", uuid,
+	).Replace(s), nil
+}
+
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// dataContentType guesses the Content-Type of a request body: JSON when it
+// looks like an object or array, form data otherwise as with curl -d.
+func dataContentType(body []byte) string {
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return "application/json"
+	}
+	return "application/x-www-form-urlencoded"
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// traceFirstByte returns req with a trace storing when the first byte of
+// the response arrived in at. Following redirects it is the last response.
+func traceFirstByte(req *http.Request, at *time.Time) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			*at = time.Now()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// bodyCounter counts the body bytes read, telling those returned by the
+// first read, which were already buffered with the response head, from
+// those which had to be transferred after it.
+type bodyCounter struct {
+	io.ReadCloser
+	first int64
+	total int64
+}
+
+func (c *bodyCounter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if c.total == 0 {
+		c.first = int64(n)
+	}
+	c.total += int64(n)
+	return n, err
+}
+
+// throughputPerfdata splits the response time at the first byte into the
+// wait for the response and the body transfer, with the bytes on either
+// side of the split and the average rate of those transferred after it.
+func throughputPerfdata(start, firstByte, end time.Time, body *bodyCounter) []string {
+	if firstByte.IsZero() {
+		firstByte = end
+	}
+	ttfb := firstByte.Sub(start).Seconds()
+	transfer := end.Sub(firstByte).Seconds()
+	transferred := body.total - body.first
+	rate := 0.0
+	if transferred > 0 && transfer > 0 {
+		rate = float64(transferred) / transfer
+	}
+	return []string{
+		fmt.Sprintf("ttfb=%.6fs;;;0", ttfb),
+		fmt.Sprintf("transfer=%.6fs;;;0", transfer),
+		fmt.Sprintf("first_read_bytes=%dB;;;0", body.first),
+		fmt.Sprintf("transfer_bytes=%dB;;;0", transferred),
+		fmt.Sprintf("body_rate=%.0f;;;0", rate),
+	}
+}
+
+// checkBodyTime reports WARNING when the body took longer than max seconds
+// to arrive after the first byte, and returns the body_transfer perfdata.
+func checkBodyTime(firstByte, end time.Time, max float64, result *Result) string {
+	if firstByte.IsZero() {
+		firstByte = end
+	}
+	transfer := end.Sub(firstByte).Seconds()
+	if transfer > max {
+		result.Add(NagiosWarning, "body transfer took %.3fs after the first byte, exceeding %.3fs", transfer, max)
+	}
+	return fmt.Sprintf("body_transfer=%.6fs;%.6f;;0", transfer, max)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// legacyCipherSuites returns every cipher suite Go implements, including
+// the insecure ones.
+func legacyCipherSuites() []uint16 {
+	var ids []uint16
+	for _, suite := range tls.CipherSuites() {
+		ids = append(ids, suite.ID)
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		ids = append(ids, suite.ID)
+	}
+	return ids
+}
+
+// forwardSecret reports whether the negotiated connection provides forward
+// secrecy. Every TLS 1.3 suite does; below that it depends on an ephemeral
+// key exchange.
+func forwardSecret(state *tls.ConnectionState) bool {
+	if state.Version >= tls.VersionTLS13 {
+		return true
+	}
+	name := tls.CipherSuiteName(state.CipherSuite)
+	return strings.Contains(name, "_ECDHE_") || strings.Contains(name, "_DHE_")
+}
+
+// checkForwardSecrecy reports status when the negotiated cipher suite uses
+// a static key exchange.
+func checkForwardSecrecy(state *tls.ConnectionState, status int, result *Result) {
+	if state == nil {
+		result.Add(NagiosUnknown, "forward secrecy check requires a TLS connection")
+		return
+	}
+	if !forwardSecret(state) {
+		result.Add(status, "cipher suite %s does not provide forward secrecy", tls.CipherSuiteName(state.CipherSuite))
+	}
+}
+
+// protocolMismatch explains errors caused by speaking TLS to a plain HTTP
+// port or plain HTTP to a TLS port. It returns "" for other errors.
+func protocolMismatch(err error, ssl bool, port int) string {
+	var recordErr tls.RecordHeaderError
+	msg := err.Error()
+	if ssl && (errors.As(err, &recordErr) || strings.Contains(msg, "server gave HTTP response to HTTPS client")) {
+		return fmt.Sprintf("server is not speaking TLS on port %d", port)
+	}
+	// a TLS alert or handshake record read as an HTTP response
+	if !ssl && strings.Contains(msg, "malformed HTTP response") && (strings.Contains(msg, `"\x15\x03`) || strings.Contains(msg, `"\x16\x03`)) {
+		return fmt.Sprintf("server is speaking TLS on port %d, use -S", port)
+	}
+	return ""
+}
+
+// plainHTTPToTLSPort matches the 400 responses servers send for plain HTTP
+// received on a TLS port.
+var plainHTTPToTLSPort = []string{
+	"The plain HTTP request was sent to HTTPS port",
+	"Client sent an HTTP request to an HTTPS server",
+}
+
+// checkPlainHTTPToTLS reports a 400 response which says that the port
+// expects TLS.
+func checkPlainHTTPToTLS(statusCode int, body []byte, port int, result *Result) {
+	if statusCode != 400 {
+		return
+	}
+	for _, s := range plainHTTPToTLSPort {
+		if bytes.Contains(body, []byte(s)) {
+			result.Add(NagiosCritical, "server is speaking TLS on port %d, use -S", port)
+			return
+		}
+	}
+}
+
+// parseCurve converts a group name such as X25519, P-256, CurveP256 or
+// secp256r1 into its CurveID.
+func parseCurve(name string) (tls.CurveID, error) {
+	if !curveSupported {
+		return 0, fmt.Errorf("--expect-curve requires a build with Go 1.25 or later")
+	}
+	key := strings.ToUpper(strings.NewReplacer("-", "", "_", "").Replace(name))
+	key = strings.TrimPrefix(key, "CURVE")
+	// OpenSSL names, secp384r1 and prime256v1
+	if strings.HasPrefix(key, "SECP") && strings.HasSuffix(key, "R1") {
+		key = "P" + key[4:len(key)-2]
+	} else if key == "PRIME256V1" {
+		key = "P256"
+	}
+	for n, id := range curveNames {
+		if strings.ToUpper(n) == key {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown curve %s", name)
+}
+
+// checkCurve reports WARNING when the negotiated key exchange group is not
+// the expected one.
+func checkCurve(state *tls.ConnectionState, expected tls.CurveID, result *Result) {
+	if state == nil {
+		result.Add(NagiosUnknown, "curve check requires a TLS connection")
+		return
+	}
+	curve := negotiatedCurve(state)
+	if curve == 0 {
+		result.Add(NagiosWarning, "expected curve %s, no ephemeral key exchange was negotiated", expected)
+		return
+	}
+	if curve != expected {
+		result.Add(NagiosWarning, "expected curve %s, negotiated %s", expected, curve)
+	}
+}
+
+// sslVersions maps the --ssl-version values to protocol versions.
+var sslVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseSSLVersion converts --ssl-version into the minimum and maximum
+// protocol version. A trailing + pins the maximum to the same version,
+// otherwise max is 0, which leaves it to Go.
+func parseSSLVersion(s string) (min, max uint16, err error) {
+	exact := strings.HasSuffix(s, "+")
+	min, ok := sslVersions[strings.TrimSuffix(s, "+")]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid --ssl-version %s, expected 1.0, 1.1, 1.2 or 1.3 with an optional +", s)
+	}
+	if exact {
+		max = min
+	}
+	return min, max, nil
+}
+
+// unsupportedVersion matches the error returned when the server picks a
+// protocol version outside the configured range.
+var unsupportedVersion = regexp.MustCompile(`unsupported protocol version ([0-9a-f]+)`)
+
+// versionMismatch explains a handshake which failed because the server
+// does not speak the version required by --ssl-version. It returns "" for
+// other errors.
+func versionMismatch(err error, sslVersion string) string {
+	required := "TLS " + strings.TrimSuffix(sslVersion, "+") + " or later"
+	if strings.HasSuffix(sslVersion, "+") {
+		required = "TLS " + strings.TrimSuffix(sslVersion, "+") + " only"
+	}
+	msg := err.Error()
+	if m := unsupportedVersion.FindStringSubmatch(msg); m != nil {
+		if v, perr := strconv.ParseUint(m[1], 16, 16); perr == nil {
+			return fmt.Sprintf("server negotiated %s, --ssl-version requires %s", tls.VersionName(uint16(v)), required)
+		}
+	}
+	if strings.Contains(msg, "protocol version not supported") {
+		return fmt.Sprintf("--ssl-version requires %s, not supported by the server: %s", required, msg)
+	}
+	return ""
+}
+
+// tlsVersionString returns the compact name of a protocol version, e.g.
+// TLS1.3.
+func tlsVersionString(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
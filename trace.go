@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// phaseTimes records when the phases of a request started and ended.
+// Following redirects each phase holds its last occurrence.
+type phaseTimes struct {
+	dnsStart, dnsDone     time.Time
+	connectStart, connect time.Time
+	tlsStart, tlsDone     time.Time
+	firstByte             time.Time
+}
+
+// tracePhases returns req with a trace recording into p.
+func tracePhases(req *http.Request, p *phaseTimes) *http.Request {
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { p.dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { p.dnsDone = time.Now() },
+		ConnectStart:      func(string, string) { p.connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { p.connect = time.Now() },
+		TLSHandshakeStart: func() { p.tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { p.tlsDone = time.Now() },
+		GotFirstResponseByte: func() {
+			p.firstByte = time.Now()
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// phaseDuration returns end - start, or zero for a phase which did not
+// happen, e.g. DNS for an address or TLS on plain HTTP.
+func phaseDuration(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start).Seconds()
+}
+
+// perfdata returns the phase durations, the time to first byte counted from
+// start.
+func (p *phaseTimes) perfdata(start time.Time) []string {
+	return []string{
+		fmt.Sprintf("dns=%.6fs;;;0", phaseDuration(p.dnsStart, p.dnsDone)),
+		fmt.Sprintf("connect=%.6fs;;;0", phaseDuration(p.connectStart, p.connect)),
+		fmt.Sprintf("tls=%.6fs;;;0", phaseDuration(p.tlsStart, p.tlsDone)),
+		fmt.Sprintf("ttfb=%.6fs;;;0", phaseDuration(start, p.firstByte)),
+	}
+}